@@ -63,6 +63,17 @@ func main() {
 	logInstance := newInitializedLog(config)
 	log := logInstance.Logger
 
+	// Reload the log level and format when the configuration file changes
+	if err := logInstance.Subscribe(config); err != nil {
+		log.Fatalf("Could not subscribe to configuration changes %v.", err)
+	}
+	if err := config.Watch(func(err error) {
+		log.Errorf("Could not reload configuration %v.", err)
+	}); err != nil {
+		log.Fatalf("Could not watch configuration %v.", err)
+	}
+	defer config.StopWatching()
+
 	// Initialize Router
 	routerInstance := newInitializedRouter(logInstance, config)
 
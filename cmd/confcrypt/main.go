@@ -0,0 +1,252 @@
+// Command confcrypt encrypts and decrypts the values of YAML configuration
+// files, the encrypted values being decrypted by conf.Configuration when they
+// are read (see conf.EncryptValue).
+// Usage:
+//	confcrypt genkey -o config.key
+//	confcrypt encrypt -key-file config.key -keys database.dbpassword -w config/production.yml
+//	confcrypt decrypt -key-file config.key config/production.yml
+//	confcrypt rotate -key-file config.key -new-key-file new.key -w config/production.yml
+// The key is read from the file given with -key-file or else from the
+// environment (see conf.LoadEncryptionKey). The result is written to the
+// standard output unless -w is set.
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
+	"github.com/cryptogarageinc/server-common-go/pkg/utils/crypto"
+	"gopkg.in/yaml.v3"
+)
+
+const usage = `usage: confcrypt <command> [flags] [file]
+
+commands:
+  genkey   generate a new base64 encoded encryption key
+  encrypt  encrypt the values of the given keys
+  decrypt  decrypt all the encrypted values
+  rotate   re-encrypt all the encrypted values with a new key`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err := run(os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(command string, args []string) error {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	keyFile := flags.String("key-file", "", "file containing the encryption key (environment by default)")
+	newKeyFile := flags.String("new-key-file", "", "file containing the new encryption key (rotate)")
+	keys := flags.String("keys", "", "comma separated list of the keys to encrypt (encrypt)")
+	output := flags.String("o", "", "output file of the generated key (genkey)")
+	write := flags.Bool("w", false, "write the result to the file instead of the standard output")
+	flags.Parse(args)
+
+	if command == "genkey" {
+		return generateKey(*output)
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%s expects a single file\n%s", command, usage)
+	}
+	path := flags.Arg(0)
+	key, err := loadKey(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	var transform func(string, *yaml.Node) error
+	switch command {
+	case "encrypt":
+		if *keys == "" {
+			return fmt.Errorf("encrypt expects the keys to encrypt (-keys)")
+		}
+		transform, err = encryptKeys(key, strings.Split(*keys, ","))
+	case "decrypt":
+		transform = reencrypt(key, nil)
+	case "rotate":
+		if *newKeyFile == "" {
+			return fmt.Errorf("rotate expects the file of the new key (-new-key-file)")
+		}
+		newKey, err := conf.ReadEncryptionKeyFile(*newKeyFile)
+		if err != nil {
+			return err
+		}
+		transform = reencrypt(key, newKey)
+	default:
+		return fmt.Errorf("unknown command [%s]\n%s", command, usage)
+	}
+	if err != nil {
+		return err
+	}
+	return transformFile(path, *write, transform)
+}
+
+func generateKey(output string) error {
+	key, err := crypto.GenerateKey(conf.EncryptionKeySize)
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(key) + "\n"
+	if output == "" {
+		fmt.Print(encoded)
+		return nil
+	}
+	return ioutil.WriteFile(output, []byte(encoded), 0600)
+}
+
+func loadKey(keyFile string) ([]byte, error) {
+	if keyFile != "" {
+		return conf.ReadEncryptionKeyFile(keyFile)
+	}
+	key, err := conf.LoadEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("no encryption key, use -key-file or set %s or %s", conf.EncryptionKeyEnv, conf.EncryptionKeyFileEnv)
+	}
+	return key, nil
+}
+
+// encryptKeys returns a transformation encrypting the values of the given keys,
+// which must all be present. Values already encrypted are left unchanged.
+func encryptKeys(key []byte, keys []string) (func(string, *yaml.Node) error, error) {
+	pending := map[string]bool{}
+	for _, k := range keys {
+		pending[strings.ToLower(strings.TrimSpace(k))] = true
+	}
+	return func(path string, node *yaml.Node) error {
+		if path == "" {
+			// called once the whole document was visited.
+			for k := range pending {
+				return fmt.Errorf("key [%s] not found or not a scalar value", k)
+			}
+			return nil
+		}
+		if !pending[path] {
+			return nil
+		}
+		delete(pending, path)
+		if conf.IsEncryptedValue(node.Value) {
+			return nil
+		}
+		encrypted, err := conf.EncryptValue(key, path, node.Value)
+		if err != nil {
+			return err
+		}
+		setValue(node, encrypted)
+		return nil
+	}, nil
+}
+
+// reencrypt returns a transformation decrypting the encrypted values and
+// encrypting them again with newKey if not nil.
+func reencrypt(key, newKey []byte) func(string, *yaml.Node) error {
+	return func(path string, node *yaml.Node) error {
+		if path == "" || !conf.IsEncryptedValue(node.Value) {
+			return nil
+		}
+		value, err := conf.DecryptValue(key, path, node.Value)
+		if err != nil {
+			return fmt.Errorf("key [%s]: %s", path, err)
+		}
+		if newKey != nil {
+			if value, err = conf.EncryptValue(newKey, path, value); err != nil {
+				return err
+			}
+		}
+		setValue(node, value)
+		return nil
+	}
+}
+
+// setValue sets the value of the node as a string, quoted when it would
+// otherwise be read as another type (e.g. "0123" or "true").
+func setValue(node *yaml.Node, value string) {
+	node.Value = value
+	node.Tag = "!!str"
+	node.Style = 0
+}
+
+// transformFile applies transform to the scalar values of the YAML file, given
+// with their dotted path, and then once with an empty path.
+func transformFile(path string, write bool, transform func(string, *yaml.Node) error) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return fmt.Errorf("failed to parse [%s]: %s", path, err)
+	}
+	if err := walk(&document, "", transform); err != nil {
+		return err
+	}
+	if err := transform("", nil); err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return err
+	}
+	if !write {
+		_, err = os.Stdout.Write(b.Bytes())
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b.Bytes(), info.Mode())
+}
+
+func walk(node *yaml.Node, path string, fn func(string, *yaml.Node) error) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if err := walk(child, path, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := walk(node.Content[i+1], joinPath(path, node.Content[i].Value), fn); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			if err := walk(child, joinPath(path, strconv.Itoa(i)), fn); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if path != "" {
+			return fn(path, node)
+		}
+	}
+	return nil
+}
+
+func joinPath(path, key string) string {
+	key = strings.ToLower(key)
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
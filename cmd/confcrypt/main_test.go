@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
+	"github.com/cryptogarageinc/server-common-go/pkg/utils/crypto"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRun_EncryptDecrypt_KeepsStrings(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := t.TempDir()
+	key, _ := crypto.GenerateKey(conf.EncryptionKeySize)
+	keyFile := filepath.Join(dir, "config.key")
+	path := filepath.Join(dir, "config.yml")
+	if err := ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	content := "database:\n  dbpassword: \"0123\"\n  enabled: \"true\"\n  name: \"1e3\"\n  user: postgres\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys := "database.dbpassword,database.enabled,database.name,database.user"
+
+	// Act
+	encryptErr := run("encrypt", []string{"-key-file", keyFile, "-keys", keys, "-w", path})
+	encrypted, _ := ioutil.ReadFile(path)
+	decryptErr := run("decrypt", []string{"-key-file", keyFile, "-w", path})
+	decrypted, _ := ioutil.ReadFile(path)
+
+	// Assert
+	assert.NoError(encryptErr)
+	assert.NoError(decryptErr)
+	assert.NotContains(string(encrypted), "0123")
+	var values map[string]map[string]interface{}
+	if assert.NoError(yaml.Unmarshal(decrypted, &values)) {
+		assert.Equal(map[string]interface{}{
+			"dbpassword": "0123",
+			"enabled":    "true",
+			"name":       "1e3",
+			"user":       "postgres",
+		}, values["database"])
+	}
+	assert.NotContains(string(decrypted), "!!str")
+}
+
+func TestRun_DecryptValueCopiedFromOtherKey_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := t.TempDir()
+	key, _ := crypto.GenerateKey(conf.EncryptionKeySize)
+	keyFile := filepath.Join(dir, "config.key")
+	path := filepath.Join(dir, "config.yml")
+	if err := ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	encrypted, _ := conf.EncryptValue(key, "database.dbpassword", "s3cr3t")
+	content := "database:\n  dbpassword: " + encrypted + "\n  user: " + encrypted + "\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	err := run("decrypt", []string{"-key-file", keyFile, "-w", path})
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "key [database.user]")
+}
@@ -0,0 +1,107 @@
+// Command configref generates the reference configuration of the components
+// of this repository, as a YAML file skeleton, a dotenv file, a Markdown table
+// or a JSON Schema, and validates configuration files against this schema.
+// Usage:
+//	configref -format yaml -components log,database -o api/examples/config/reference.yml
+//	configref -format markdown -app myapp
+//	configref -format jsonschema -o config.schema.json
+//	configref -validate config/production.yml
+// Services can document their own component configurations using
+// conf.Reference and the conf.WriteReference functions.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
+	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
+	"github.com/cryptogarageinc/server-common-go/pkg/log"
+)
+
+var components = map[string]interface{}{
+	"log":      &log.Config{},
+	"database": &orm.Config{},
+}
+
+func main() {
+	format := flag.String("format", "yaml", "output format: yaml, env, markdown or jsonschema")
+	appName := flag.String("app", "", "application name used as prefix of the environment variables")
+	names := flag.String("components", "log,database", "comma separated list of components: "+strings.Join(componentNames(), ", "))
+	output := flag.String("o", "", "output file (standard output by default)")
+	validate := flag.String("validate", "", "configuration file to validate instead of generating the reference")
+	flag.Parse()
+
+	if err := run(*format, *appName, *names, *output, *validate); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(format, appName, names, output, validate string) (err error) {
+	var compConfs []interface{}
+	for _, name := range strings.Split(names, ",") {
+		compConf, ok := components[strings.TrimSpace(name)]
+		if !ok {
+			return fmt.Errorf("unknown component [%s]", name)
+		}
+		compConfs = append(compConfs, compConf)
+	}
+	if validate != "" {
+		if err := conf.ValidateFile(validate, compConfs...); err != nil {
+			return err
+		}
+		fmt.Printf("%s is valid\n", validate)
+		return nil
+	}
+	entries, err := conf.Reference(compConfs...)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, createErr := os.Create(output)
+		if createErr != nil {
+			return createErr
+		}
+		defer func() {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		w = f
+	}
+
+	switch format {
+	case "yaml", "yml":
+		return conf.WriteReferenceYAML(w, entries)
+	case "env":
+		return conf.WriteReferenceEnv(w, appName, entries)
+	case "markdown", "md":
+		return conf.WriteReferenceMarkdown(w, appName, entries)
+	case "jsonschema":
+		schema, err := conf.Schema(compConfs...)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(schema)
+	}
+	return fmt.Errorf("unsupported format [%s]", format)
+}
+
+func componentNames() []string {
+	res := make([]string, 0, len(components))
+	for name := range components {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
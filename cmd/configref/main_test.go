@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	exampleConfigPath   = "../../api/examples/config/default.example.yml"
+	referenceConfigPath = "../../api/examples/config/reference.yml"
+)
+
+func TestExampleConfig_AllComponents_Loads(t *testing.T) {
+	t.Setenv("DB_PASSWORD", "password")
+	for _, path := range []string{exampleConfigPath, referenceConfigPath} {
+		for _, name := range componentNames() {
+			testConfigFileLoads(t, path, name)
+		}
+	}
+}
+
+func testConfigFileLoads(t *testing.T, path, name string) {
+	t.Run(filepath.Base(path)+"/"+name, func(t *testing.T) {
+		// Arrange
+		assert := assert.New(t)
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		config, err := conf.NewConfigurationFromReader("yaml", f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compConf := reflect.New(reflect.TypeOf(components[name]).Elem()).Interface()
+
+		// Act
+		err = config.InitializeComponentConfig(compConf)
+		validateErr := conf.ValidateFile(path, components[name])
+
+		// Assert
+		assert.NoError(err)
+		assert.NoError(validateErr)
+	})
+}
+
+func TestExampleConfig_ServerSection_IsSet(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	f, err := os.Open(exampleConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// Act
+	config, err := conf.NewConfigurationFromReader("yaml", f)
+
+	// Assert
+	assert.NoError(err)
+	// required by the rest and grpc examples.
+	assert.NotEmpty(config.GetString("server.address"))
+}
+
+func TestRun_YAMLFormat_MatchesReferenceConfig(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	output := filepath.Join(t.TempDir(), "reference.yml")
+	expected, err := ioutil.ReadFile(referenceConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	err = run("yaml", "", "log,database", output, "")
+
+	// Assert
+	assert.NoError(err)
+	actual, _ := ioutil.ReadFile(output)
+	assert.Equal(string(expected), string(actual), "run make config-reference-yaml to regenerate %s", referenceConfigPath)
+}
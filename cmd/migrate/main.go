@@ -0,0 +1,243 @@
+// Command migrate applies the versioned SQL migrations of a directory (see
+// orm.LoadSQLMigrations) to the database configured by the database keys of
+// the configuration, as read by orm.Config.
+// Usage:
+//	migrate -config config -appname myapp -e production up
+//	migrate -config config -appname myapp -e production up -dry-run
+//	migrate -config config -appname myapp -e production down 1
+//	migrate -config config -appname myapp -e production status
+//	migrate -dir migrations create add_user_email
+//	migrate -config config -appname myapp -e production force 20210601120000_add_user_email
+//	migrate -config config -appname myapp -e production validate
+// Services with migrations written in Go can build a similar command with
+// orm.Migrator.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
+	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
+	"github.com/cryptogarageinc/server-common-go/pkg/log"
+)
+
+const usage = `usage: migrate [flags] <command> [arguments]
+
+commands:
+  up [-dry-run]   apply the pending migrations, or print their statements
+  down [n]        roll back the last n applied migrations (1 by default)
+  status          list the migrations and their status
+  create <name>   create the up and down files of a new migration
+  force <id>      record the migrations up to id as applied without running them
+  validate        check the migration files against the applied migrations
+
+flags:`
+
+var (
+	configPath = flag.String("config", "config", "Path to the directory containing the configuration files.")
+	appName    = flag.String("appname", "", "The name of the application. Will be use as a prefix for environment variables.")
+	envname    = flag.String("e", "default", "environment (ex., \"development\"). Should match with the name of the configuration file.")
+	dir        = flag.String("dir", "migrations", "Directory containing the <id>.up.sql and <id>.down.sql migration files.")
+)
+
+var migrationNameRegexp = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// defaultLogConfig is used when the configuration has no log section.
+var defaultLogConfig = &log.Config{OutputStdout: true, LogFormat: "text", LogLevel: "info"}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(command string, args []string) error {
+	if command == "create" {
+		if len(args) != 1 {
+			return fmt.Errorf("create expects the name of the migration")
+		}
+		return create(args[0], os.Stdout)
+	}
+
+	ormInstance, finalize, err := newORM()
+	if err != nil {
+		return err
+	}
+	defer finalize()
+	migrator, err := newMigrator(ormInstance)
+	if err != nil {
+		return err
+	}
+	return execute(migrator, command, args, os.Stdout)
+}
+
+// execute runs the command with the given migrator, writing its output to w.
+func execute(migrator *orm.Migrator, command string, args []string, w io.Writer) error {
+	switch command {
+	case "up":
+		if len(args) == 1 && args[0] == "-dry-run" {
+			return migrator.WriteDryRun(w)
+		}
+		return migrator.Up()
+	case "down":
+		n := 1
+		if len(args) == 1 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations [%s]", args[0])
+			}
+		}
+		return migrator.Down(n)
+	case "status":
+		return status(migrator, w)
+	case "force":
+		if len(args) != 1 {
+			return fmt.Errorf("force expects the ID of the migration")
+		}
+		return migrator.Force(args[0])
+	case "validate":
+		return validate(migrator, w)
+	}
+	return fmt.Errorf("unknown command [%s]", command)
+}
+
+// newORM returns the ORM of the database of the configuration, and the
+// function finalizing it. The logs are written to the standard output if the
+// configuration has no log section.
+func newORM() (*orm.ORM, func(), error) {
+	config := conf.NewConfiguration(*appName, *envname, []string{*configPath})
+	if err := config.Initialize(); err != nil {
+		return nil, nil, fmt.Errorf("could not read configuration: %v", err)
+	}
+	logConfig := defaultLogConfig
+	if config.Sub("log") != nil {
+		logConfig = &log.Config{}
+		if err := config.InitializeComponentConfig(logConfig); err != nil {
+			return nil, nil, err
+		}
+	}
+	logInstance := log.NewLog(logConfig)
+	if err := logInstance.Initialize(); err != nil {
+		return nil, nil, err
+	}
+	ormConfig := &orm.Config{}
+	if err := config.InitializeComponentConfig(ormConfig); err != nil {
+		return nil, nil, err
+	}
+	ormInstance := orm.NewORM(ormConfig, logInstance)
+	if err := ormInstance.Initialize(); err != nil {
+		logInstance.Finalize()
+		return nil, nil, err
+	}
+	return ormInstance, func() {
+		ormInstance.Finalize()
+		logInstance.Finalize()
+	}, nil
+}
+
+// newMigrator returns a migrator of the SQL migrations of the directory.
+func newMigrator(ormInstance *orm.ORM) (*orm.Migrator, error) {
+	migrations, err := orm.LoadSQLMigrations(os.DirFS(*dir), ".")
+	if err != nil {
+		return nil, err
+	}
+	migrator := orm.NewMigrator(ormInstance)
+	if err := migrator.Register(migrations...); err != nil {
+		return nil, err
+	}
+	return migrator, nil
+}
+
+func create(name string, w io.Writer) error {
+	if !migrationNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid migration name [%s], expecting lower case letters, digits and underscores", name)
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+	id := time.Now().UTC().Format("20060102150405") + "_" + name
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(*dir, id+"."+direction+".sql")
+		content := fmt.Sprintf("-- %s: %s\n", id, direction)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, path)
+	}
+	return nil
+}
+
+func status(migrator *orm.Migrator, out io.Writer) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := ""
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.ID, statusName(s), appliedAt)
+	}
+	return w.Flush()
+}
+
+func statusName(s orm.MigrationStatus) string {
+	switch {
+	case s.Missing:
+		return "missing"
+	case s.Modified:
+		return "modified"
+	case s.Applied:
+		return "applied"
+	}
+	return "pending"
+}
+
+// validate checks that the applied migrations were neither modified nor
+// removed.
+func validate(migrator *orm.Migrator, w io.Writer) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	var problems []string
+	pending := 0
+	for _, s := range statuses {
+		switch {
+		case s.Missing:
+			problems = append(problems, fmt.Sprintf("migration [%s] is applied but its files are missing", s.ID))
+		case s.Modified:
+			problems = append(problems, fmt.Sprintf("migration [%s] was modified after it was applied", s.ID))
+		case !s.Applied:
+			pending++
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid migrations:\n%s", strings.Join(problems, "\n"))
+	}
+	fmt.Fprintf(w, "migrations are valid (%d pending)\n", pending)
+	return nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	createUsersID = "20210101000000_create_users"
+	createRolesID = "20210102000000_create_roles"
+)
+
+// setupMigrateTest sets the flags to a configuration of an in memory sqlite
+// database without log section, and to a directory of two migrations.
+func setupMigrateTest(t *testing.T) {
+	root := t.TempDir()
+	oldConfigPath, oldEnvname, oldDir := *configPath, *envname, *dir
+	t.Cleanup(func() {
+		*configPath, *envname, *dir = oldConfigPath, oldEnvname, oldDir
+	})
+	*configPath, *envname, *dir = root, "migratetest", filepath.Join(root, "migrations")
+	require.NoError(t, os.Mkdir(*dir, 0755))
+
+	writeTestFile(t, filepath.Join(root, "migratetest.yml"),
+		"database:\n  inmemory: true\n  host: sqlite\n  port: 5432\n  dbpassword: password\n  maxIdleConnections: 1\n  maxOpenConnections: 1\n")
+	writeTestFile(t, filepath.Join(*dir, createUsersID+".up.sql"), "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	writeTestFile(t, filepath.Join(*dir, createUsersID+".down.sql"), "DROP TABLE users;")
+	writeTestFile(t, filepath.Join(*dir, createRolesID+".up.sql"), "CREATE TABLE roles (id INTEGER PRIMARY KEY);")
+	writeTestFile(t, filepath.Join(*dir, createRolesID+".down.sql"), "DROP TABLE roles;")
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+}
+
+func newTestMigrator(t *testing.T) (*orm.ORM, *orm.Migrator) {
+	ormInstance, finalize, err := newORM()
+	require.NoError(t, err)
+	t.Cleanup(finalize)
+	migrator, err := newMigrator(ormInstance)
+	require.NoError(t, err)
+	return ormInstance, migrator
+}
+
+// executeStatus returns the status of each migration, by ID.
+func executeStatus(t *testing.T, migrator *orm.Migrator) map[string]string {
+	var b strings.Builder
+	require.NoError(t, execute(migrator, "status", nil, &b))
+	res := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n")[1:] {
+		fields := strings.Fields(line)
+		res[fields[0]] = fields[1]
+	}
+	return res
+}
+
+func TestCreate_WithName_WritesMigrationFiles(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setupMigrateTest(t)
+	*dir = filepath.Join(t.TempDir(), "new")
+	var b strings.Builder
+
+	// Act
+	err := create("add_user_email", &b)
+
+	// Assert
+	assert.NoError(err)
+	paths := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Len(paths, 2)
+	fileRegexp := regexp.MustCompile(`^([0-9]{14}_add_user_email)\.(up|down)\.sql$`)
+	for i, direction := range []string{"up", "down"} {
+		assert.Equal(*dir, filepath.Dir(paths[i]))
+		match := fileRegexp.FindStringSubmatch(filepath.Base(paths[i]))
+		if assert.NotNil(match) {
+			assert.Equal(direction, match[2])
+			content, err := ioutil.ReadFile(paths[i])
+			assert.NoError(err)
+			assert.Equal("-- "+match[1]+": "+direction+"\n", string(content))
+		}
+	}
+}
+
+func TestCreate_WithInvalidName_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setupMigrateTest(t)
+
+	// Act
+	err := create("Add-User", &strings.Builder{})
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "invalid migration name")
+}
+
+func TestNewORM_WithoutLogSection_UsesDefaultLog(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setupMigrateTest(t)
+
+	// Act
+	ormInstance, finalize, err := newORM()
+
+	// Assert
+	assert.NoError(err)
+	if assert.NotNil(ormInstance) {
+		assert.True(ormInstance.IsInitialized())
+		finalize()
+	}
+}
+
+func TestExecute_Status_ListsPendingMigrations(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setupMigrateTest(t)
+	_, migrator := newTestMigrator(t)
+
+	// Act
+	statuses := executeStatus(t, migrator)
+
+	// Assert
+	assert.Equal(map[string]string{createUsersID: "pending", createRolesID: "pending"}, statuses)
+}
+
+func TestExecute_UpDown_AppliesAndRollsBackMigrations(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setupMigrateTest(t)
+	ormInstance, migrator := newTestMigrator(t)
+
+	// Act
+	upErr := execute(migrator, "up", nil, &strings.Builder{})
+	afterUp := executeStatus(t, migrator)
+	downErr := execute(migrator, "down", []string{"1"}, &strings.Builder{})
+	afterDown := executeStatus(t, migrator)
+
+	// Assert
+	assert.NoError(upErr)
+	assert.Equal(map[string]string{createUsersID: "applied", createRolesID: "applied"}, afterUp)
+	assert.NoError(downErr)
+	assert.Equal(map[string]string{createUsersID: "applied", createRolesID: "pending"}, afterDown)
+	assert.True(ormInstance.GetDB().Migrator().HasTable("users"))
+	assert.False(ormInstance.GetDB().Migrator().HasTable("roles"))
+}
+
+func TestExecute_UpDryRun_PrintsStatements(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setupMigrateTest(t)
+	ormInstance, migrator := newTestMigrator(t)
+	var b strings.Builder
+
+	// Act
+	err := execute(migrator, "up", []string{"-dry-run"}, &b)
+
+	// Assert
+	assert.NoError(err)
+	assert.Contains(b.String(), "CREATE TABLE users")
+	assert.False(ormInstance.GetDB().Migrator().HasTable("users"))
+}
+
+func TestExecute_DownWithInvalidNumber_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setupMigrateTest(t)
+	_, migrator := newTestMigrator(t)
+
+	// Act
+	err := execute(migrator, "down", []string{"0"}, &strings.Builder{})
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "invalid number of migrations [0]")
+}
+
+func TestExecute_Force_RecordsMigrationsAsApplied(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setupMigrateTest(t)
+	ormInstance, migrator := newTestMigrator(t)
+
+	// Act
+	err := execute(migrator, "force", []string{createUsersID}, &strings.Builder{})
+	statuses := executeStatus(t, migrator)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(map[string]string{createUsersID: "applied", createRolesID: "pending"}, statuses)
+	assert.False(ormInstance.GetDB().Migrator().HasTable("users"))
+}
+
+func TestExecute_Validate_WithPendingMigrations_Succeeds(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setupMigrateTest(t)
+	_, migrator := newTestMigrator(t)
+	var b strings.Builder
+
+	// Act
+	err := execute(migrator, "validate", nil, &b)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("migrations are valid (2 pending)\n", b.String())
+}
+
+func TestExecute_Validate_WithModifiedMigration_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setupMigrateTest(t)
+	ormInstance, migrator := newTestMigrator(t)
+	require.NoError(t, execute(migrator, "up", nil, &strings.Builder{}))
+	writeTestFile(t, filepath.Join(*dir, createRolesID+".up.sql"), "CREATE TABLE roles (id INTEGER);")
+	migrator, err := newMigrator(ormInstance)
+	require.NoError(t, err)
+
+	// Act
+	err = execute(migrator, "validate", nil, &strings.Builder{})
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "migration ["+createRolesID+"] was modified after it was applied")
+}
+
+func TestExecute_UnknownCommand_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setupMigrateTest(t)
+	_, migrator := newTestMigrator(t)
+
+	// Act
+	err := execute(migrator, "redo", nil, &strings.Builder{})
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "unknown command [redo]")
+}
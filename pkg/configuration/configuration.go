@@ -2,14 +2,18 @@ package conf
 
 import (
 	"encoding/hex"
+	"fmt"
 	"io"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cryptogarageinc/server-common-go/pkg/utils/iso8601"
 
 	"github.com/pkg/errors"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 	"gopkg.in/go-playground/validator.v9"
 )
@@ -21,6 +25,23 @@ type Configuration struct {
 	paths           []string
 	viper           *viper.Viper
 	initialized     bool
+
+	// mu guards viper which is replaced on reload.
+	mu            sync.RWMutex
+	reloadMu      sync.Mutex
+	defaults      *defaultValues
+	subscriptions []*subscription
+	watcher       *watcher
+	sources       []Source
+	loaded        []loadedSource
+	resolvers     map[string]SecretResolver
+	encryption    *encryptionKey
+	secretKeys    *keySet
+	usedKeys      *keySet
+	strict        bool
+	layeredFiles  bool
+	// prefix is the path of a sub configuration from the root configuration.
+	prefix string
 }
 
 // Encoding represent the encoding used.
@@ -40,6 +61,7 @@ const (
 	hexTagValue      = "hex"
 	durationTagValue = "duration"
 	iso8601TagValue  = "iso8601"
+	secretTagValue   = "secret"
 )
 
 var (
@@ -68,6 +90,11 @@ func NewConfiguration(appName, envname string, searchPaths []string) *Configurat
 		paths:       searchPaths,
 		viper:       viper.New(),
 		initialized: false,
+		defaults:    newDefaultValues(),
+		resolvers:   defaultSecretResolvers(),
+		encryption:  &encryptionKey{},
+		secretKeys:  newKeySet(),
+		usedKeys:    newKeySet(),
 	}
 }
 
@@ -76,36 +103,45 @@ func NewConfiguration(appName, envname string, searchPaths []string) *Configurat
 // Supported formats are "json", "toml", "yaml", "yml", "properties", "props",
 // "prop", "hcl".
 func NewConfigurationFromReader(format string, in io.Reader) (*Configuration, error) {
-	v := viper.New()
-	v.SetConfigType(format)
-	if err := v.ReadConfig(in); err != nil {
+	source, err := NewReaderSource("reader:"+format, format, in)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to init Configuration with %s", in)
+	}
+	c := &Configuration{
+		defaults:   newDefaultValues(),
+		resolvers:  defaultSecretResolvers(),
+		encryption: &encryptionKey{},
+		secretKeys: newKeySet(),
+		usedKeys:   newKeySet(),
+	}
+	c.sources = []Source{source}
+	v, loaded, err := c.load()
+	if err != nil {
 		return nil, errors.Wrapf(err, "failed to init Configuration with %s", in)
 	}
-	c := &Configuration{viper: v}
+	c.setState(v, loaded)
 	c.initialized = true
 	return c, nil
 }
 
-// Initialize initializes the configuration.
+// Initialize initializes the configuration by loading its sources (see
+// AddSource).
 func (c *Configuration) Initialize() error {
-	if strings.TrimSpace(c.EnvironmentName) == "" {
-		return errors.Errorf("environment name is missing")
-	}
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
 
-	c.viper.SetConfigName(c.EnvironmentName)
-	for _, path := range c.paths {
-		c.viper.AddConfigPath(path)
+	sources, err := c.standardSources()
+	if err != nil {
+		return err
 	}
+	c.sources = sources
 
-	err := c.viper.ReadInConfig()
+	v, loaded, err := c.load()
 	if err != nil {
-		return errors.Wrapf(err, "failed to read config file: [%s] (suffix ommitted)", c.EnvironmentName)
+		return err
 	}
 
-	c.viper.SetEnvPrefix(c.AppName)
-	c.viper.AutomaticEnv()
-	c.viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-
+	c.setState(v, loaded)
 	c.initialized = true
 	return nil
 }
@@ -119,33 +155,33 @@ func (c *Configuration) IsInitialized() bool {
 // GetInt returns the values associated with the given key as an integer.
 func (c *Configuration) GetInt(key string) int {
 	c.ensureInitialized()
-	return c.viper.GetInt(key)
+	return cast.ToInt(c.get(key))
 }
 
 // GetString returns the values associated with the given key as a string.
 func (c *Configuration) GetString(key string) string {
 	c.ensureInitialized()
-	return c.viper.GetString(key)
+	return cast.ToString(c.get(key))
 }
 
 // GetStringSlice returns the values associated with the given key as a string
 // slice.
 func (c *Configuration) GetStringSlice(key string) []string {
 	c.ensureInitialized()
-	return c.viper.GetStringSlice(key)
+	return cast.ToStringSlice(c.get(key))
 }
 
 // GetBool returns the values associated with the given key as a boolean.
 func (c *Configuration) GetBool(key string) bool {
 	c.ensureInitialized()
-	return c.viper.GetBool(key)
+	return cast.ToBool(c.get(key))
 }
 
 // GetByte returns the values associated with the given key as a byte array
 // using the given encoding.
 func (c *Configuration) GetByte(key string, enc Encoding) (b []byte, err error) {
 	c.ensureInitialized()
-	v := c.viper.GetString(key)
+	v := c.GetString(key)
 
 	switch enc {
 	case UTF8:
@@ -164,7 +200,7 @@ func (c *Configuration) GetByte(key string, enc Encoding) (b []byte, err error)
 func (c *Configuration) GetDuration(key string, isISO8601 bool) time.Duration {
 	c.ensureInitialized()
 	if !isISO8601 {
-		return c.viper.GetDuration(key)
+		return cast.ToDuration(c.get(key))
 	}
 	str := c.GetString(key)
 	if len(str) == 0 {
@@ -181,65 +217,99 @@ func (c *Configuration) GetDuration(key string, isISO8601 bool) time.Duration {
 // viper automatically detects the format so this method is mainly for testing.
 func (c *Configuration) SetFormat(formatType string) {
 	c.ensureInitialized()
-	c.viper.SetConfigType(formatType)
+	c.getViper().SetConfigType(formatType)
 }
 
 // GetFloat64 returns the values associated with the given key as a float64.
 func (c *Configuration) GetFloat64(key string) float64 {
 	c.ensureInitialized()
-	return c.viper.GetFloat64(key)
+	return cast.ToFloat64(c.get(key))
 }
 
 // GetInt64 returns the values associated with the given key as a int64.
 func (c *Configuration) GetInt64(key string) int64 {
 	c.ensureInitialized()
-	return c.viper.GetInt64(key)
+	return cast.ToInt64(c.get(key))
 }
 
 // GetUInt32 returns the values associated with the given key as a uint32.
 func (c *Configuration) GetUInt32(key string) uint32 {
 	c.ensureInitialized()
-	return c.viper.GetUint32(key)
+	return cast.ToUint32(c.get(key))
 }
 
 // GetUInt8 returns the values associated with the given key as a uint8.
 func (c *Configuration) GetUInt8(key string) uint8 {
 	c.ensureInitialized()
-	return uint8(c.viper.GetUint(key))
+	return uint8(cast.ToUint(c.get(key)))
 }
 
 // GetUInt64 returns the values associated with the given key as a uint64.
 func (c *Configuration) GetUInt64(key string) uint64 {
 	c.ensureInitialized()
-	return c.viper.GetUint64(key)
+	return cast.ToUint64(c.get(key))
 }
 
 // GetStringMap returns the values associated with the given key as a map[string]T{}.
+// T can be a struct (or a pointer to a struct) initialized as a component
+// configuration, or any type supported by InitializeComponentConfig.
+// Entries can be added with environment variables (see Sub).
+// A *ConfigError listing the invalid entries is returned if some entries
+// cannot be decoded.
 func (c *Configuration) GetStringMap(key string, vType reflect.Type) (interface{}, error) {
 	c.ensureInitialized()
-	untypedMap := c.viper.GetStringMap(key)
+	untypedMap := c.getViper().GetStringMap(c.prefix + key)
+	for _, name := range c.envEntries(key, vType) {
+		if _, ok := untypedMap[name]; !ok {
+			untypedMap[name] = nil
+		}
+	}
 	mapType := reflect.MapOf(reflect.TypeOf(""), vType)
 	res := reflect.MakeMap(mapType)
+	names := make([]string, 0, len(untypedMap))
 	for k := range untypedMap {
-		subconf := c.Sub(key + "." + k)
-		instance := reflect.New(vType)
-		value := instance.Interface()
-		if err := subconf.InitializeComponentConfig(value); err != nil {
-			return nil, err
+		names = append(names, k)
+	}
+	// the entries are decoded in order so that their errors are reported in
+	// order.
+	sort.Strings(names)
+	configErr := &ConfigError{}
+	for _, k := range names {
+		entryKey := key + "." + k
+		var value reflect.Value
+		var err error
+		if isStructType(vType) {
+			value, err = c.decodeStructItem(entryKey, vType)
+		} else {
+			var raw interface{}
+			raw, err = c.getE(entryKey)
+			if err == nil {
+				value, err = c.decodeValue(entryKey, raw, vType, nil)
+			}
+		}
+		if err != nil {
+			configErr.add(c, entryKey, err)
+			continue
 		}
-		res.SetMapIndex(reflect.ValueOf(k), instance.Elem())
+		res.SetMapIndex(reflect.ValueOf(k), value)
+	}
+	if len(configErr.Errors) > 0 {
+		return nil, configErr
 	}
 	return res.Interface(), nil
 }
 
 // GetStruct returns the initialized struct sub configuration value associated
-// with the given key.
+// with the given key. A FieldError is returned if the key does not hold a map.
 func (c *Configuration) GetStruct(key string, vType reflect.Type) (interface{}, error) {
 	c.ensureInitialized()
-	if !c.viper.IsSet(key) {
+	if !c.getViper().IsSet(c.prefix+key) && len(c.envVariables(key)) == 0 {
 		return nil, errors.Errorf("GetStruct Error undefined key %s", key)
 	}
 	subConfig := c.Sub(key)
+	if subConfig == nil {
+		return nil, c.fieldError(key, fmt.Sprintf("must be a map to be decoded as %v", vType))
+	}
 	res := reflect.New(vType).Interface()
 	if err := subConfig.InitializeComponentConfig(res); err != nil {
 		return nil, err
@@ -250,13 +320,13 @@ func (c *Configuration) GetStruct(key string, vType reflect.Type) (interface{},
 // GetFloat32 returns the values associated with the given key as a float32.
 func (c *Configuration) GetFloat32(key string) float32 {
 	c.ensureInitialized()
-	return float32(c.viper.GetFloat64(key))
+	return float32(cast.ToFloat64(c.get(key)))
 }
 
 // GetTime returns the string value associated with the given key as a time.
 func (c *Configuration) GetTime(key string) (time.Time, error) {
 	c.ensureInitialized()
-	str := c.viper.GetString(key)
+	str := c.GetString(key)
 	return time.ParseInLocation(time.RFC3339, str, time.UTC)
 }
 
@@ -265,6 +335,16 @@ func (c *Configuration) GetTime(key string) (time.Time, error) {
 // configuration file for a given field as well as to determine default values,
 // and validation tags for validation (see
 // https://godoc.org/gopkg.in/go-playground/validator.v9).
+// Supported field types are booleans, numbers, strings, time.Duration,
+// time.Time, url.URL, []byte, types implementing encoding.TextUnmarshaler (e.g.
+// net.IP), types with a decoder registered with RegisterDecoder, as well as
+// pointers, slices, arrays, maps with string keys and structs of these types.
+// Pointer fields are left nil when the key is not set. Slices can be given as
+// lists or as comma separated strings.
+// A *ConfigError listing every invalid value, with its key and source, is
+// returned if some fields cannot be decoded or validated.
+// The "secret" option of the configkey tag marks sensitive fields whose values
+// are redacted in configuration dumps (e.g. `configkey:"db.password,secret"`).
 // Example:
 //type TestConfig struct {
 //	I        int           `configkey:"unittest.i" validate:"min=10" default:"10"`
@@ -296,135 +376,169 @@ func (c *Configuration) InitializeComponentConfig(compConf interface{}) error {
 		panic("Configuration cannot be set, try passing it as a reference.")
 	}
 
+	configErr := &ConfigError{}
+	keys := map[string]string{}
+	failed := map[string]bool{}
 	for i := 0; i < v.NumField(); i++ {
 		field := v.Field(i)
 		tField := t.Field(i)
-		tagValues := strings.Split(tField.Tag.Get(configTagName), ",")
+		tagValues, isSecret := parseConfigTag(tField.Tag.Get(configTagName))
 		tag := tagValues[0]
 		if tag == "" {
 			continue
 		}
+		keys[tField.Name] = tag
+		if isSecret {
+			c.secretKeys.add(c.prefix + tag)
+		}
+		if !isStructType(field.Type()) && !(field.Kind() == reflect.Map && isStructType(field.Type().Elem())) {
+			c.usedKeys.add(c.prefix + tag)
+		}
 
 		defaultValue := tField.Tag.Get(defaultTagName)
 		if defaultValue != "" {
 			if values := strings.Split(defaultValue, ","); len(values) > 1 {
-				c.viper.SetDefault(tag, values)
+				c.setDefault(tag, values)
 			} else {
-				c.viper.SetDefault(tag, defaultValue)
+				c.setDefault(tag, defaultValue)
 			}
 		}
 
-		switch field.Kind() {
-		case reflect.Int:
-			value := c.GetInt(tag)
-			field.SetInt(int64(value))
-		case reflect.Int64:
-			if len(tagValues) > 1 && tagValues[1] == durationTagValue {
-				isIso8601 := false
-				if len(tagValues) > 2 && tagValues[2] == iso8601TagValue {
-					isIso8601 = true
-				}
-				value := c.GetDuration(tag, isIso8601)
-				field.Set(reflect.ValueOf(value))
-			} else {
-				value := c.GetInt64(tag)
-				field.SetInt(int64(value))
-			}
-		case reflect.String:
-			value := c.GetString(tag)
-			field.SetString(value)
-		case reflect.Bool:
-			value := c.GetBool(tag)
-			field.SetBool(value)
-		case reflect.Uint8:
-			value := c.GetUInt8(tag)
-			field.SetUint(uint64(value))
-		case reflect.Uint32:
-			value := c.GetUInt32(tag)
-			field.SetUint(uint64(value))
-		case reflect.Uint64:
-			value := c.GetUInt64(tag)
-			field.SetUint(value)
-		case reflect.Float32:
-			value := c.GetFloat32(tag)
-			field.SetFloat(float64(value))
-		case reflect.Float64:
-			value := c.GetFloat64(tag)
-			field.SetFloat(value)
-		case reflect.Map:
-			value, err := c.GetStringMap(tag, field.Type().Elem())
-			if err != nil {
-				return err
-			}
-			field.Set(reflect.ValueOf(value))
-		case reflect.Struct:
-			fType := field.Type()
-			var value interface{}
-			var err error
-			if fType == timeType {
-				value, err = c.GetTime(tag)
-			} else {
-				value, err = c.GetStruct(tag, fType)
-			}
-			if err != nil {
-				return err
-			}
-			field.Set(reflect.Indirect(reflect.ValueOf(value)))
-		default:
-			fieldType := field.Type()
-			switch fieldType {
-			case stringSliceType:
-				value := c.GetStringSlice(tag)
-				field.Set(reflect.ValueOf(value))
-			case byteArrayType:
-				var encoding Encoding
-				var encodingTag string
-				if len(tagValues) > 1 {
-					encodingTag = tagValues[1]
-				} else {
-					encodingTag = ""
-				}
-				if encodingTag == "" || encodingTag == utf8TagValue {
-					encoding = UTF8
-				} else if encodingTag == hexTagValue {
-					encoding = HEX
-				} else {
-					return errors.Errorf("Unknown encoding %v", encodingTag)
-				}
-				value, err := c.GetByte(tag, encoding)
-				if err != nil {
-					return errors.Errorf("Could not parse byte %v.", tag)
-				}
-				field.Set(reflect.ValueOf(value))
-			default:
-				return errors.Errorf("Unknown field type %v.", fieldType.Name())
-			}
+		if err := c.bindField(field, tag, tagValues[1:]); err != nil {
+			configErr.add(c, tag, err)
+			failed[tField.Name] = true
 		}
 	}
 
 	validate := validator.New()
-	return validate.Struct(compConf)
+	if err := validate.Struct(compConf); err != nil {
+		validationErrors, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return err
+		}
+		configErr.addValidationErrors(c, validationErrors, keys, failed)
+	}
+	if len(configErr.Errors) > 0 {
+		return configErr
+	}
+	return nil
 }
 
 // Sub returns a new initialized SubConfiguration
 // return nil if the tag is not present
+// The keys of a sub configuration are the keys relative to the tag, they are
+// read from the same sources as the keys of the parent configuration. In
+// particular, the environment variables overriding the keys of a sub
+// configuration are the ones of their full path, e.g. "APP_SUB_PORT" for the
+// key "port" of the sub configuration "sub" of the application "app" (see
+// EnvSource.VariableName). The tag is present if it holds a map or if an
+// environment variable overrides one of its children.
 func (c *Configuration) Sub(tag string) *Configuration {
-	subViper := c.viper.Sub(tag)
-	if subViper == nil {
+	v := c.getViper()
+	if _, ok := toStringMap(v.Get(c.prefix + tag)); !ok && len(c.envVariables(tag)) == 0 {
 		return nil
 	}
-	subAppName := c.AppName + "." + tag
-	subViper.SetEnvPrefix(subAppName)
-	subViper.AutomaticEnv()
-	subViper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	return &Configuration{
-		AppName:         subAppName,
+		AppName:         c.AppName + "." + tag,
 		EnvironmentName: c.EnvironmentName,
 
 		paths:       c.paths,
-		viper:       subViper,
+		viper:       v,
 		initialized: true,
+		defaults:    c.defaults,
+		loaded:      c.getLoaded(),
+		resolvers:   c.getResolvers(),
+		encryption:  c.encryption,
+		secretKeys:  c.secretKeys,
+		usedKeys:    c.usedKeys,
+		strict:      c.IsStrict(),
+		prefix:      c.prefix + tag + ".",
+	}
+}
+
+// parseConfigTag splits the value of a configkey tag into the key followed by
+// its options, the secret option being removed and returned separately.
+func parseConfigTag(tagValue string) ([]string, bool) {
+	values := strings.Split(tagValue, ",")
+	res := values[:1]
+	isSecret := false
+	for _, option := range values[1:] {
+		if option == secretTagValue {
+			isSecret = true
+			continue
+		}
+		res = append(res, option)
+	}
+	return res, isSecret
+}
+
+// get returns the value associated with the given key after interpolation,
+// decryption and resolution of the secret references. It panics if the value
+// cannot be resolved, the getters returning an error (e.g. GetStringE) being
+// used to handle this case.
+func (c *Configuration) get(key string) interface{} {
+	value, err := c.getE(key)
+	if err != nil {
+		panic(errors.Wrapf(err, "failed to read key [%s]", c.prefix+key))
+	}
+	return value
+}
+
+// getE returns the value associated with the given key after interpolation,
+// including the secret references, and decryption of the encrypted values.
+func (c *Configuration) getE(key string) (interface{}, error) {
+	path := c.prefix + key
+	c.usedKeys.add(path)
+	value, err := c.interpolate(path, c.rawValue(path), []string{strings.ToLower(path)})
+	if err != nil {
+		return nil, err
 	}
+	return c.decryptValues(path, value)
+}
+
+// withViper returns a configuration sharing the settings of c but reading its
+// values from v.
+func (c *Configuration) withViper(v *viper.Viper, loaded []loadedSource) *Configuration {
+	return &Configuration{
+		AppName:         c.AppName,
+		EnvironmentName: c.EnvironmentName,
+
+		paths:       c.paths,
+		viper:       v,
+		initialized: true,
+		defaults:    c.defaults,
+		loaded:      loaded,
+		resolvers:   c.getResolvers(),
+		encryption:  c.encryption,
+		secretKeys:  c.secretKeys,
+		usedKeys:    c.usedKeys,
+		strict:      c.IsStrict(),
+		prefix:      c.prefix,
+	}
+}
+
+// getViper returns the viper instance currently holding the configuration
+// values.
+func (c *Configuration) getViper() *viper.Viper {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper
+}
+
+// setState replaces the viper instance holding the configuration values and
+// the sources they were loaded from.
+func (c *Configuration) setState(v *viper.Viper, loaded []loadedSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.viper = v
+	c.loaded = loaded
+}
+
+// setDefault sets the default value of the given key and records it so that
+// it survives a reload.
+func (c *Configuration) setDefault(key string, value interface{}) {
+	c.defaults.set(c.prefix+key, value)
+	c.getViper().SetDefault(c.prefix+key, value)
 }
 
 func (c *Configuration) ensureInitialized() {
@@ -230,6 +230,23 @@ func TestConfigurationGetStruct_WithNestedStruct_ReturnsCorrectValue(t *testing.
 	assert.Equal(expected, actual)
 }
 
+func TestConfigurationGetStruct_WithScalarValue_ReturnsFieldError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, _ := NewConfigurationFromReader("yaml", strings.NewReader("unittest:\n  nested_struct: 1\n"))
+
+	// Act
+	actual, err := config.GetStruct("unittest.nested_struct", reflect.TypeOf(NestedTestConfig{}))
+
+	// Assert
+	assert.Nil(actual)
+	var fieldError FieldError
+	if assert.ErrorAs(err, &fieldError) {
+		assert.Equal("unittest.nested_struct", fieldError.Key)
+		assert.Equal("must be a map to be decoded as conf.NestedTestConfig", fieldError.Reason)
+	}
+}
+
 func TestConfiguration_WithEnvironmentVariable_ReturnsEnvironmentVariableValue(t *testing.T) {
 	// Arrange
 	expected := 100
@@ -328,7 +345,7 @@ type InvalidHexTestConfig struct {
 }
 
 type UnknownTypeConfig struct {
-	UnknownType []int `configkey:"unittest.unkowntype"`
+	UnknownType chan int `configkey:"unittest.unkowntype"`
 }
 
 func TestConfiguration_InitializeComponentConfig_CorrectlyInitializesConfig(t *testing.T) {
@@ -0,0 +1,445 @@
+package conf
+
+import (
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/utils/iso8601"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+// DecoderFunc decodes a raw configuration value (string, number, bool, slice
+// or map as read from the sources) into a value of the type it was registered
+// for.
+type DecoderFunc func(raw interface{}) (interface{}, error)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	urlType             = reflect.TypeOf(url.URL{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	decodersMu sync.RWMutex
+	decoders   = map[reflect.Type]DecoderFunc{
+		urlType: decodeURL,
+	}
+)
+
+// RegisterDecoder registers a decoder used by InitializeComponentConfig for the
+// fields of the given type, slices and pointers of this type included.
+// Registered decoders take precedence over the built-in conversions.
+// Example:
+//	conf.RegisterDecoder(reflect.TypeOf(big.Int{}), func(raw interface{}) (interface{}, error) {
+//		i, ok := new(big.Int).SetString(cast.ToString(raw), 10)
+//		if !ok {
+//			return nil, errors.New("invalid integer")
+//		}
+//		return *i, nil
+//	})
+func RegisterDecoder(t reflect.Type, decoder DecoderFunc) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[t] = decoder
+}
+
+func lookupDecoder(t reflect.Type) (DecoderFunc, bool) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	decoder, ok := decoders[t]
+	return decoder, ok
+}
+
+func decodeURL(raw interface{}) (interface{}, error) {
+	u, err := url.Parse(cast.ToString(raw))
+	if err != nil {
+		return nil, err
+	}
+	return *u, nil
+}
+
+// bindField sets the field with the value of the given key.
+// Unset keys leave pointers nil and set the zero value of other types, except
+// for time and struct fields which require a value.
+func (c *Configuration) bindField(field reflect.Value, key string, options []string) error {
+	t := field.Type()
+	if _, ok := lookupDecoder(t); !ok {
+		switch {
+		case t.Kind() == reflect.Map:
+			// the keys of the configuration maps are strings.
+			if t.Key().Kind() != reflect.String {
+				return decodeError(key, t)
+			}
+			value, err := c.GetStringMap(key, t.Elem())
+			if err != nil {
+				return err
+			}
+			field.Set(convertMapKeys(reflect.ValueOf(value), t))
+			return nil
+		case t == timeType:
+			value, err := c.GetTime(key)
+			if err != nil {
+				return decodeError(key, referenceTypeName(t, options))
+			}
+			field.Set(reflect.ValueOf(value))
+			return nil
+		case t.Kind() == reflect.Struct && !isTextType(t):
+			value, err := c.GetStruct(key, t)
+			if err != nil {
+				return err
+			}
+			field.Set(reflect.Indirect(reflect.ValueOf(value)))
+			return nil
+		case t == stringSliceType:
+			raw, err := c.getE(key)
+			if err != nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(cast.ToStringSlice(raw)))
+			return nil
+		}
+	}
+
+	raw, err := c.getE(key)
+	if err != nil {
+		return err
+	}
+	value, err := c.decodeValue(key, raw, t, options)
+	if err != nil {
+		return err
+	}
+	field.Set(value)
+	return nil
+}
+
+// convertMapKeys returns the map m with string keys as a map of type t, whose
+// keys are of a string kind.
+func convertMapKeys(m reflect.Value, t reflect.Type) reflect.Value {
+	if m.Type().AssignableTo(t) {
+		return m
+	}
+	res := reflect.MakeMapWithSize(t, m.Len())
+	iter := m.MapRange()
+	for iter.Next() {
+		res.SetMapIndex(iter.Key().Convert(t.Key()), iter.Value())
+	}
+	return res
+}
+
+// decodeValue converts a raw value of the given key into a value of type t.
+func (c *Configuration) decodeValue(key string, raw interface{}, t reflect.Type, options []string) (reflect.Value, error) {
+	if decoder, ok := lookupDecoder(t); ok {
+		if raw == nil {
+			return reflect.Zero(t), nil
+		}
+		decoded, err := decoder(raw)
+		if err != nil {
+			return reflect.Value{}, decodeError(key, t)
+		}
+		value := reflect.ValueOf(decoded)
+		if !value.IsValid() || !value.Type().AssignableTo(t) {
+			return reflect.Value{}, errors.Errorf("decoder of %v returned %T for key [%s]", t, decoded, key)
+		}
+		return value, nil
+	}
+
+	switch {
+	case t.Kind() == reflect.Ptr:
+		if raw == nil {
+			return reflect.Zero(t), nil
+		}
+		var elem reflect.Value
+		var err error
+		if t.Elem().Kind() == reflect.Struct && !isTextType(t.Elem()) && t.Elem() != timeType {
+			var value interface{}
+			value, err = c.GetStruct(key, t.Elem())
+			if err == nil {
+				elem = reflect.Indirect(reflect.ValueOf(value))
+			}
+		} else {
+			elem, err = c.decodeValue(key, raw, t.Elem(), options)
+		}
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case t == durationType || (t.Kind() == reflect.Int64 && hasOption(options, durationTagValue)):
+		d, err := decodeDuration(raw, hasOption(options, iso8601TagValue))
+		if err != nil {
+			return reflect.Value{}, decodeError(key, referenceTypeName(t, options))
+		}
+		return reflect.ValueOf(d).Convert(t), nil
+	case t == timeType:
+		tm, err := time.ParseInLocation(time.RFC3339, cast.ToString(raw), time.UTC)
+		if err != nil {
+			return reflect.Value{}, decodeError(key, referenceTypeName(t, options))
+		}
+		return reflect.ValueOf(tm), nil
+	case isTextType(t):
+		value := reflect.New(t)
+		if raw == nil {
+			return value.Elem(), nil
+		}
+		if err := value.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(cast.ToString(raw))); err != nil {
+			return reflect.Value{}, decodeError(key, t)
+		}
+		return value.Elem(), nil
+	case t == byteArrayType:
+		return decodeBytes(key, raw, options)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return c.decodeSlice(key, raw, t, options)
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		value, err := c.GetStringMap(key, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(value).Convert(t), nil
+	case t.Kind() == reflect.Struct:
+		value, err := c.GetStruct(key, t)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.Indirect(reflect.ValueOf(value)), nil
+	}
+	return decodeScalar(key, raw, t)
+}
+
+// decodeSlice converts a list, or a comma separated string, into a slice (or
+// array) of type t. A *ConfigError listing the invalid items is returned if
+// some items cannot be decoded.
+func (c *Configuration) decodeSlice(key string, raw interface{}, t reflect.Type, options []string) (reflect.Value, error) {
+	var items []interface{}
+	switch r := raw.(type) {
+	case nil:
+	case string:
+		if strings.TrimSpace(r) != "" {
+			for _, item := range strings.Split(r, ",") {
+				items = append(items, strings.TrimSpace(item))
+			}
+		}
+	default:
+		rv := reflect.ValueOf(raw)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			items = []interface{}{raw}
+			break
+		}
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, rv.Index(i).Interface())
+		}
+	}
+
+	var res reflect.Value
+	if t.Kind() == reflect.Array {
+		if len(items) > t.Len() {
+			return reflect.Value{}, errors.Errorf("key [%s] has %d values, at most %d expected", key, len(items), t.Len())
+		}
+		res = reflect.New(t).Elem()
+	} else {
+		if raw == nil {
+			return reflect.Zero(t), nil
+		}
+		res = reflect.MakeSlice(t, len(items), len(items))
+	}
+
+	configErr := &ConfigError{}
+	elemType := t.Elem()
+	for i, item := range items {
+		itemKey := fmt.Sprintf("%s.%d", key, i)
+		var value reflect.Value
+		var err error
+		if isStructType(elemType) {
+			value, err = c.decodeStructItem(itemKey, elemType)
+		} else {
+			value, err = c.decodeValue(itemKey, item, elemType, options)
+		}
+		if err != nil {
+			configErr.add(c, itemKey, err)
+			continue
+		}
+		res.Index(i).Set(value)
+	}
+	if len(configErr.Errors) > 0 {
+		return reflect.Value{}, configErr
+	}
+	return res, nil
+}
+
+// decodeStructItem decodes a map entry or a list item holding a struct (or a
+// pointer to a struct). The item is read as a sub configuration.
+func (c *Configuration) decodeStructItem(key string, t reflect.Type) (reflect.Value, error) {
+	structType := t
+	if t.Kind() == reflect.Ptr {
+		structType = t.Elem()
+	}
+	sub := c.Sub(key)
+	if sub == nil {
+		return reflect.Value{}, errors.Errorf("key [%s] must be a map to be decoded as %v", key, structType)
+	}
+	value := reflect.New(structType)
+	if err := sub.InitializeComponentConfig(value.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	if t.Kind() == reflect.Ptr {
+		return value, nil
+	}
+	return value.Elem(), nil
+}
+
+// decodeBytes converts a string into bytes using the encoding given in the
+// options (utf8 by default).
+func decodeBytes(key string, raw interface{}, options []string) (reflect.Value, error) {
+	var encodingTag string
+	if len(options) > 0 {
+		encodingTag = options[0]
+	}
+	s := cast.ToString(raw)
+	switch encodingTag {
+	case "", utf8TagValue:
+		return reflect.ValueOf([]byte(s)), nil
+	case hexTagValue:
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return reflect.Value{}, errors.Errorf("Could not parse byte %v.", key)
+		}
+		return reflect.ValueOf(b), nil
+	}
+	return reflect.Value{}, errors.Errorf("Unknown encoding %v", encodingTag)
+}
+
+// decodeDuration converts a raw value into a duration using the ISO8601
+// format or the go format (e.g. "1h30m").
+func decodeDuration(raw interface{}, isISO8601 bool) (time.Duration, error) {
+	if raw == nil {
+		return 0, nil
+	}
+	if !isISO8601 {
+		return cast.ToDurationE(raw)
+	}
+	str := cast.ToString(raw)
+	if len(str) == 0 {
+		return 0, nil
+	}
+	return iso8601.ParseDuration(str)
+}
+
+// decodeScalar converts a raw value into a boolean, number or string of type
+// t.
+func decodeScalar(key string, raw interface{}, t reflect.Type) (reflect.Value, error) {
+	value := reflect.New(t).Elem()
+	if !isScalarType(t) {
+		return reflect.Value{}, errors.Errorf("Unknown field type %v.", t)
+	}
+	if raw == nil {
+		return value, nil
+	}
+
+	var err error
+	switch t.Kind() {
+	case reflect.Bool:
+		var b bool
+		if b, err = cast.ToBoolE(raw); err == nil {
+			value.SetBool(b)
+		}
+	case reflect.String:
+		var s string
+		if s, err = cast.ToStringE(raw); err == nil {
+			value.SetString(s)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = cast.ToInt64E(raw); err == nil {
+			if value.OverflowInt(i) {
+				err = errors.New("overflow")
+			} else {
+				value.SetInt(i)
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		if u, err = cast.ToUint64E(raw); err == nil {
+			if value.OverflowUint(u) {
+				err = errors.New("overflow")
+			} else {
+				value.SetUint(u)
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = cast.ToFloat64E(raw); err == nil {
+			if value.OverflowFloat(f) {
+				err = errors.New("overflow")
+			} else {
+				value.SetFloat(f)
+			}
+		}
+	case reflect.Complex64, reflect.Complex128:
+		var cplx complex128
+		if cplx, err = strconv.ParseComplex(cast.ToString(raw), t.Bits()); err == nil {
+			value.SetComplex(cplx)
+		}
+	case reflect.Interface:
+		value.Set(reflect.ValueOf(raw))
+	}
+	if err != nil {
+		return reflect.Value{}, decodeError(key, t)
+	}
+	return value, nil
+}
+
+// decodeError returns the error of a value of the given key which cannot be
+// decoded as typeName. The error of the conversion is dropped as it may
+// contain the value, which may be a secret.
+func decodeError(key string, typeName interface{}) error {
+	return errors.Errorf("failed to decode key [%s] as %v", key, typeName)
+}
+
+// isScalarType returns whether t is a boolean, number, string or empty
+// interface type.
+func isScalarType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	}
+	return false
+}
+
+// isTextType returns whether the values of type t can be decoded using
+// encoding.TextUnmarshaler.
+func isTextType(t reflect.Type) bool {
+	return t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// isStructType returns whether t is a struct (or a pointer to a struct)
+// decoded field by field.
+func isStructType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if _, ok := lookupDecoder(t); ok {
+		return false
+	}
+	return t.Kind() == reflect.Struct && t != timeType && !isTextType(t)
+}
+
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package conf
+
+import (
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+)
+
+type DecodeTestItem struct {
+	Name string `configkey:"name" validate:"required"`
+	Port int    `configkey:"port" default:"80"`
+}
+
+type DecodeTestConfig struct {
+	Ints      []int            `configkey:"decode.ints"`
+	CSVInts   []int            `configkey:"decode.csv_ints"`
+	Durations []time.Duration  `configkey:"decode.durations"`
+	Name      *string          `configkey:"decode.name"`
+	Missing   *string          `configkey:"decode.missing"`
+	Items     []DecodeTestItem `configkey:"decode.items"`
+	Pointer   *DecodeTestItem  `configkey:"decode.pointer"`
+	I32       int32            `configkey:"decode.i32"`
+	UI16      uint16           `configkey:"decode.ui16"`
+	URL       url.URL          `configkey:"decode.url"`
+	IP        net.IP           `configkey:"decode.ip"`
+	Limits    map[string]int   `configkey:"decode.limits"`
+}
+
+const decodeTestContent = `
+decode:
+  ints: [1, 2, 3]
+  csv_ints: "4, 5"
+  durations: [1s, 2m]
+  name: hoge
+  items:
+    - name: first
+      port: 8080
+    - name: second
+  pointer:
+    name: fuga
+  i32: -32
+  ui16: 16
+  url: https://example.com:8443/path
+  ip: 192.168.0.1
+  limits:
+    a: 1
+    b: 2
+`
+
+func createDecodeConfiguration(t *testing.T, content string) *Configuration {
+	config, err := NewConfigurationFromReader("yaml", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("reading configuration failed: %s", err)
+	}
+	return config
+}
+
+func TestConfigurationInitializeComponentConfig_WithExtendedTypes_InitializesFields(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, decodeTestContent)
+	decodeConfig := &DecodeTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(decodeConfig)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]int{1, 2, 3}, decodeConfig.Ints)
+	assert.Equal([]int{4, 5}, decodeConfig.CSVInts)
+	assert.Equal([]time.Duration{time.Second, 2 * time.Minute}, decodeConfig.Durations)
+	if assert.NotNil(decodeConfig.Name) {
+		assert.Equal("hoge", *decodeConfig.Name)
+	}
+	assert.Nil(decodeConfig.Missing)
+	assert.Equal([]DecodeTestItem{{Name: "first", Port: 8080}, {Name: "second", Port: 80}}, decodeConfig.Items)
+	if assert.NotNil(decodeConfig.Pointer) {
+		assert.Equal(DecodeTestItem{Name: "fuga", Port: 80}, *decodeConfig.Pointer)
+	}
+	assert.Equal(int32(-32), decodeConfig.I32)
+	assert.Equal(uint16(16), decodeConfig.UI16)
+	assert.Equal("example.com:8443", decodeConfig.URL.Host)
+	assert.Equal("/path", decodeConfig.URL.Path)
+	assert.Equal(net.ParseIP("192.168.0.1"), decodeConfig.IP)
+	assert.Equal(map[string]int{"a": 1, "b": 2}, decodeConfig.Limits)
+}
+
+func TestConfigurationInitializeComponentConfig_WithOverflow_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, "decode:\n  ui16: 70000\n")
+	decodeConfig := &DecodeTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(decodeConfig)
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "decode.ui16")
+}
+
+type DecodeTestName string
+
+type DecodeTestMapKeysConfig struct {
+	Named map[DecodeTestName]int `configkey:"decode.named"`
+	Ints  map[int]string         `configkey:"decode.ints"`
+}
+
+func TestConfigurationInitializeComponentConfig_WithNonStringMapKeys_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, "decode:\n  named:\n    a: 1\n  ints:\n    1: one\n")
+	decodeConfig := &DecodeTestMapKeysConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(decodeConfig)
+
+	// Assert
+	var configErr *ConfigError
+	if assert.ErrorAs(err, &configErr) && assert.Len(configErr.Errors, 1) {
+		assert.Equal("decode.ints", configErr.Errors[0].Key)
+		assert.Equal("failed to decode key [decode.ints] as map[int]string", configErr.Errors[0].Reason)
+	}
+	assert.Equal(map[DecodeTestName]int{"a": 1}, decodeConfig.Named)
+}
+
+func TestConfigurationInitializeComponentConfig_WithInvalidListItem_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, "decode:\n  items:\n    - port: 1\n")
+	decodeConfig := &DecodeTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(decodeConfig)
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestConfigurationInitializeComponentConfig_WithInvalidIP_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, "decode:\n  ip: not-an-ip\n")
+	decodeConfig := &DecodeTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(decodeConfig)
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "decode.ip")
+}
+
+type DecoderTestConfig struct {
+	Amount  big.Int    `configkey:"decoder.amount"`
+	Amounts []*big.Int `configkey:"decoder.amounts"`
+}
+
+func TestConfigurationInitializeComponentConfig_WithRegisteredDecoder_UsesDecoder(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	decode := func(raw interface{}) (big.Int, error) {
+		i, ok := new(big.Int).SetString(cast.ToString(raw), 10)
+		if !ok {
+			return big.Int{}, errors.New("invalid integer")
+		}
+		return *i, nil
+	}
+	RegisterDecoder(reflect.TypeOf(big.Int{}), func(raw interface{}) (interface{}, error) {
+		return decode(raw)
+	})
+	RegisterDecoder(reflect.TypeOf(&big.Int{}), func(raw interface{}) (interface{}, error) {
+		i, err := decode(raw)
+		return &i, err
+	})
+	config := createDecodeConfiguration(t, `
+decoder:
+  amount: "123456789012345678901234567890"
+  amounts: ["1", "2"]
+`)
+	decoderConfig := &DecoderTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(decoderConfig)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("123456789012345678901234567890", decoderConfig.Amount.String())
+	if assert.Len(decoderConfig.Amounts, 2) {
+		assert.Equal("1", decoderConfig.Amounts[0].String())
+		assert.Equal("2", decoderConfig.Amounts[1].String())
+	}
+}
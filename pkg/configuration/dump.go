@@ -0,0 +1,162 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// RedactedValue replaces the values of secret fields in configuration dumps.
+const RedactedValue = "******"
+
+// keySet is a set of configuration keys safe for concurrent use.
+type keySet struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func newKeySet() *keySet {
+	return &keySet{keys: map[string]bool{}}
+}
+
+func (s *keySet) add(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[strings.ToLower(key)] = true
+}
+
+func (s *keySet) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		res = append(res, key)
+	}
+	return res
+}
+
+func (s *keySet) has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[strings.ToLower(key)]
+}
+
+// AllSettings returns the effective configuration as a tree of values: the
+// merged values of all sources including the environment variable overrides
+// and the default values. The values of the fields marked with the secret
+// option are replaced by RedactedValue and secret references are returned
+// unresolved.
+// Default values and secret fields are only known for the component
+// configurations which were initialized.
+func (c *Configuration) AllSettings() map[string]interface{} {
+	c.ensureInitialized()
+	v := c.getViper()
+	res := map[string]interface{}{}
+	for _, key := range c.allKeys() {
+		value := v.Get(c.prefix + key)
+		if value == nil {
+			value = c.defaults.get(c.prefix + key)
+		}
+		if value == nil {
+			continue
+		}
+		if c.isSecretKey(c.prefix + key) {
+			value = RedactedValue
+		}
+		setPath(res, strings.Split(key, "."), normalizeValue(value))
+	}
+	return res
+}
+
+// Dump returns the effective configuration (see AllSettings) encoded using
+// the given format, "yaml" or "json".
+func (c *Configuration) Dump(format string) ([]byte, error) {
+	settings := c.AllSettings()
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		return yaml.Marshal(settings)
+	case "json":
+		return json.MarshalIndent(settings, "", "  ")
+	}
+	return nil, errors.Errorf("unsupported dump format [%s]", format)
+}
+
+// allKeys returns the sorted keys holding a value in the configuration, and
+// the keys of the registered default values.
+func (c *Configuration) allKeys() []string {
+	keys := map[string]bool{}
+	prefix := strings.ToLower(c.prefix)
+	for _, key := range c.getViper().AllKeys() {
+		if strings.HasPrefix(key, prefix) {
+			keys[strings.TrimPrefix(key, prefix)] = true
+		}
+	}
+	for _, key := range c.defaults.keysWithPrefix(c.prefix) {
+		keys[key] = true
+	}
+	res := make([]string, 0, len(keys))
+	for key := range keys {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return removeParentKeys(res)
+}
+
+// isSecretKey returns whether the given key or one of its parents belongs to
+// a field marked with the secret option.
+func (c *Configuration) isSecretKey(key string) bool {
+	path := strings.Split(key, ".")
+	for i := range path {
+		if c.secretKeys.has(strings.Join(path[:i+1], ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeParentKeys removes the keys having children, as their value is given
+// by their children.
+func removeParentKeys(keys []string) []string {
+	parents := map[string]bool{}
+	for _, key := range keys {
+		path := strings.Split(key, ".")
+		for i := 1; i < len(path); i++ {
+			parents[strings.Join(path[:i], ".")] = true
+		}
+	}
+	res := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !parents[key] {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+// normalizeValue converts the maps contained in value to maps with string
+// keys so that they can be encoded in any format.
+func normalizeValue(value interface{}) interface{} {
+	if m, ok := toStringMap(value); ok {
+		res := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			res[k] = normalizeValue(v)
+		}
+		return res
+	}
+	if s, ok := value.([]interface{}); ok {
+		res := make([]interface{}, len(s))
+		for i, v := range s {
+			res[i] = normalizeValue(v)
+		}
+		return res
+	}
+	if _, ok := value.(fmt.Stringer); ok {
+		return fmt.Sprint(value)
+	}
+	return value
+}
@@ -0,0 +1,104 @@
+package conf
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type DumpTestConfig struct {
+	Host     string           `configkey:"dump.host" default:"localhost"`
+	Port     int              `configkey:"dump.port" default:"5432"`
+	Password string           `configkey:"dump.password,secret"`
+	Nested   NestedDumpConfig `configkey:"dump.nested"`
+}
+
+type NestedDumpConfig struct {
+	Token string `configkey:"token,secret"`
+	Name  string `configkey:"name" default:"nested"`
+}
+
+func createDumpConfiguration(t *testing.T) *Configuration {
+	content := "dump:\n  port: 1234\n  password: s3cr3t\n  nested:\n    token: t0k3n\n  extra: value\n"
+	config, err := NewConfigurationFromReader("yaml", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("reading configuration failed: %s", err)
+	}
+	config.InitializeComponentConfig(&DumpTestConfig{})
+	return config
+}
+
+func TestConfigurationAllSettings_WithSecretFields_RedactsValues(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDumpConfiguration(t)
+	expected := map[string]interface{}{
+		"dump": map[string]interface{}{
+			"host":     "localhost",
+			"port":     1234,
+			"password": RedactedValue,
+			"extra":    "value",
+			"nested": map[string]interface{}{
+				"token": RedactedValue,
+				"name":  "nested",
+			},
+		},
+	}
+
+	// Act
+	actual := config.AllSettings()
+
+	// Assert
+	assert.Equal(expected, actual)
+}
+
+func TestConfigurationDump_WithEnvOverride_DumpsEffectiveValue(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := t.TempDir()
+	writeConfigFile(t, dir+"/dump.yaml", "dump:\n  host: file\n  password: ${secret:file:///run/secrets/password}\n")
+	os.Setenv("DUMPTEST_DUMP_HOST", "env")
+	defer os.Unsetenv("DUMPTEST_DUMP_HOST")
+	config := NewConfiguration("dumptest", "dump", []string{dir})
+	config.Initialize()
+
+	// Act
+	dump, err := config.Dump("json")
+
+	// Assert
+	assert.NoError(err)
+	var actual map[string]map[string]interface{}
+	assert.NoError(json.Unmarshal(dump, &actual))
+	assert.Equal("env", actual["dump"]["host"])
+	assert.Equal("${secret:file:///run/secrets/password}", actual["dump"]["password"])
+}
+
+func TestConfigurationDump_WithYamlFormat_RedactsValues(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDumpConfiguration(t)
+
+	// Act
+	dump, err := config.Dump("yaml")
+
+	// Assert
+	assert.NoError(err)
+	assert.Contains(string(dump), "password: '******'")
+	assert.NotContains(string(dump), "s3cr3t")
+	assert.NotContains(string(dump), "t0k3n")
+}
+
+func TestConfigurationDump_WithUnknownFormat_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDumpConfiguration(t)
+
+	// Act
+	_, err := config.Dump("xml")
+
+	// Assert
+	assert.Error(err)
+}
@@ -0,0 +1,208 @@
+package conf
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/utils/crypto"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EncryptionKeyEnv is the environment variable holding the base64 encoded
+	// key used to decrypt the encrypted values.
+	EncryptionKeyEnv = "CONFIG_ENCRYPTION_KEY"
+	// EncryptionKeyFileEnv is the environment variable holding the path of a
+	// file containing the base64 encoded key, used if EncryptionKeyEnv is not
+	// set.
+	EncryptionKeyFileEnv = "CONFIG_ENCRYPTION_KEY_FILE"
+	// EncryptionKeySize is the size in bytes of the encryption keys.
+	EncryptionKeySize = 32
+
+	encryptedPrefix = "ENC[AES256_GCM,"
+	encryptedSuffix = "]"
+)
+
+// encryptionKey holds the key used to decrypt the values of a configuration
+// and of its sub configurations.
+type encryptionKey struct {
+	mu  sync.Mutex
+	key []byte
+}
+
+// IsEncryptedValue returns whether value is an encrypted value of the form
+// ENC[AES256_GCM,<base64 encoded nonce, ciphertext and tag>].
+func IsEncryptedValue(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix) && strings.HasSuffix(value, encryptedSuffix)
+}
+
+// EncryptValue encrypts value with AES-256-GCM using the given key and returns
+// it in the ENC[AES256_GCM,...] form. The value is bound to the full path of
+// the configuration key it is stored in (list items being given by their
+// index, e.g. hosts.0), so that it cannot be decrypted from another key.
+func EncryptValue(key []byte, configKey, value string) (string, error) {
+	if len(key) != EncryptionKeySize {
+		return "", errors.Errorf("encryption key must be %d bytes long", EncryptionKeySize)
+	}
+	ciphertext, err := crypto.EncryptAESGCM(key, []byte(value), additionalData(configKey))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encrypt value")
+	}
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(ciphertext) + encryptedSuffix, nil
+}
+
+// DecryptValue decrypts a value returned by EncryptValue for the same
+// configuration key.
+func DecryptValue(key []byte, configKey, value string) (string, error) {
+	if !IsEncryptedValue(value) {
+		return "", errors.New("value is not an encrypted value")
+	}
+	if len(key) != EncryptionKeySize {
+		return "", errors.Errorf("encryption key must be %d bytes long", EncryptionKeySize)
+	}
+	encoded := strings.TrimSuffix(strings.TrimPrefix(value, encryptedPrefix), encryptedSuffix)
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.New("invalid base64 encrypted value")
+	}
+	plaintext, err := crypto.DecryptAESGCM(key, ciphertext, additionalData(configKey))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decrypt value")
+	}
+	return string(plaintext), nil
+}
+
+// additionalData returns the data authenticated with the values encrypted for
+// the given configuration key.
+func additionalData(configKey string) []byte {
+	return []byte(strings.ToLower(configKey))
+}
+
+// ParseEncryptionKey decodes a base64 (standard encoding) encryption key.
+func ParseEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.New("invalid base64 encryption key")
+	}
+	if len(key) != EncryptionKeySize {
+		return nil, errors.Errorf("encryption key must be %d bytes long", EncryptionKeySize)
+	}
+	return key, nil
+}
+
+// ReadEncryptionKeyFile reads the base64 encoded encryption key stored in the
+// file at the given path.
+func ReadEncryptionKeyFile(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read encryption key file [%s]", path)
+	}
+	key, err := ParseEncryptionKey(string(content))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid encryption key file [%s]", path)
+	}
+	return key, nil
+}
+
+// LoadEncryptionKey returns the encryption key given by the EncryptionKeyEnv
+// environment variable or else by the file referenced by EncryptionKeyFileEnv.
+// A nil key is returned if none of them is set.
+func LoadEncryptionKey() ([]byte, error) {
+	if encoded, ok := os.LookupEnv(EncryptionKeyEnv); ok && encoded != "" {
+		key, err := ParseEncryptionKey(encoded)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", EncryptionKeyEnv)
+		}
+		return key, nil
+	}
+	if path, ok := os.LookupEnv(EncryptionKeyFileEnv); ok && path != "" {
+		return ReadEncryptionKeyFile(path)
+	}
+	return nil, nil
+}
+
+// SetEncryptionKey sets the key used to decrypt the encrypted values. If no key
+// is set, the key is loaded with LoadEncryptionKey when the first encrypted
+// value is read.
+func (c *Configuration) SetEncryptionKey(key []byte) error {
+	if len(key) != EncryptionKeySize {
+		return errors.Errorf("encryption key must be %d bytes long", EncryptionKeySize)
+	}
+	c.encryption.mu.Lock()
+	defer c.encryption.mu.Unlock()
+	c.encryption.key = key
+	return nil
+}
+
+// getEncryptionKey returns the key set with SetEncryptionKey or else loaded
+// from the environment.
+func (c *Configuration) getEncryptionKey() ([]byte, error) {
+	c.encryption.mu.Lock()
+	defer c.encryption.mu.Unlock()
+	if c.encryption.key != nil {
+		return c.encryption.key, nil
+	}
+	key, err := LoadEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.Errorf("no encryption key, set %s or %s", EncryptionKeyEnv, EncryptionKeyFileEnv)
+	}
+	c.encryption.key = key
+	return key, nil
+}
+
+// decryptValues replaces the encrypted values contained in value (string or
+// slice) read for the given key by their decrypted values, the elements of a
+// slice being decrypted for the key of their index. The decrypted value is
+// never part of the returned error.
+func (c *Configuration) decryptValues(key string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return c.decryptValue(key, v)
+	case []string:
+		res := make([]string, len(v))
+		for i, s := range v {
+			decrypted, err := c.decryptValue(key+"."+strconv.Itoa(i), s)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = decrypted
+		}
+		return res, nil
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			res[i] = e
+			if s, ok := e.(string); ok {
+				decrypted, err := c.decryptValue(key+"."+strconv.Itoa(i), s)
+				if err != nil {
+					return nil, err
+				}
+				res[i] = decrypted
+			}
+		}
+		return res, nil
+	}
+	return value, nil
+}
+
+// decryptValue decrypts value if it is an encrypted value.
+func (c *Configuration) decryptValue(key, value string) (string, error) {
+	if !IsEncryptedValue(value) {
+		return value, nil
+	}
+	encryptionKey, err := c.getEncryptionKey()
+	if err == nil {
+		value, err = DecryptValue(encryptionKey, key, value)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to decrypt key [%s]", key)
+	}
+	return value, nil
+}
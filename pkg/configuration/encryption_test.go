@@ -0,0 +1,186 @@
+package conf
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type EncryptionTestConfig struct {
+	Password string `configkey:"encryption.password,secret"`
+	Port     int    `configkey:"encryption.port"`
+}
+
+func createEncryptionKey() []byte {
+	key := make([]byte, EncryptionKeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+func createEncryptedConfiguration(t *testing.T, key []byte) *Configuration {
+	password, _ := EncryptValue(key, "encryption.password", "s3cr3t")
+	port, _ := EncryptValue(key, "encryption.port", "5432")
+	content := "encryption:\n  password: " + password + "\n  port: " + port + "\n"
+	config, err := NewConfigurationFromReader("yaml", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("reading configuration failed: %s", err)
+	}
+	return config
+}
+
+func TestDecryptValue_WithEncryptedValue_ReturnsValue(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key := createEncryptionKey()
+	encrypted, _ := EncryptValue(key, "encryption.password", "value")
+
+	// Act
+	actual, err := DecryptValue(key, "encryption.password", encrypted)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("value", actual)
+	assert.True(IsEncryptedValue(encrypted))
+	assert.True(strings.HasPrefix(encrypted, "ENC[AES256_GCM,"))
+}
+
+func TestDecryptValue_WithOtherKey_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key := createEncryptionKey()
+	otherKey := make([]byte, EncryptionKeySize)
+	encrypted, _ := EncryptValue(key, "encryption.password", "value")
+
+	// Act
+	_, err := DecryptValue(otherKey, "encryption.password", encrypted)
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestDecryptValue_WithOtherConfigKey_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key := createEncryptionKey()
+	encrypted, _ := EncryptValue(key, "encryption.password", "value")
+
+	// Act
+	_, err := DecryptValue(key, "encryption.user", encrypted)
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestConfigurationInitializeComponentConfig_WithEncryptedValues_DecryptsValues(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key := createEncryptionKey()
+	config := createEncryptedConfiguration(t, key)
+	config.SetEncryptionKey(key)
+	encryptionConfig := &EncryptionTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(encryptionConfig)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(&EncryptionTestConfig{Password: "s3cr3t", Port: 5432}, encryptionConfig)
+	assert.True(IsEncryptedValue(config.Sub("encryption").AllSettings()["port"].(string)))
+}
+
+func TestConfigurationGetString_WithKeyFromEnv_DecryptsValue(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key := createEncryptionKey()
+	config := createEncryptedConfiguration(t, key)
+	setEnv(t, map[string]string{EncryptionKeyEnv: base64.StdEncoding.EncodeToString(key)})
+
+	// Act
+	actual := config.Sub("encryption").GetString("password")
+
+	// Assert
+	assert.Equal("s3cr3t", actual)
+}
+
+func TestConfigurationGetString_WithKeyFile_DecryptsValue(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key := createEncryptionKey()
+	config := createEncryptedConfiguration(t, key)
+	path := filepath.Join(t.TempDir(), "config.key")
+	writeConfigFile(t, path, base64.StdEncoding.EncodeToString(key)+"\n")
+	setEnv(t, map[string]string{EncryptionKeyFileEnv: path})
+
+	// Act
+	actual := config.GetString("encryption.password")
+
+	// Assert
+	assert.Equal("s3cr3t", actual)
+}
+
+func TestConfigurationInitializeComponentConfig_WithoutKey_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createEncryptedConfiguration(t, createEncryptionKey())
+	encryptionConfig := &EncryptionTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(encryptionConfig)
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "encryption.password")
+	assert.Contains(err.Error(), EncryptionKeyEnv)
+}
+
+func TestConfigurationSetEncryptionKey_WithInvalidSize_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createEncryptedConfiguration(t, createEncryptionKey())
+
+	// Act
+	err := config.SetEncryptionKey([]byte("short"))
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestConfigurationGetStringE_WithValueCopiedFromOtherKey_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key := createEncryptionKey()
+	password, _ := EncryptValue(key, "encryption.password", "s3cr3t")
+	content := "encryption:\n  password: " + password + "\n  user: " + password + "\n"
+	config, _ := NewConfigurationFromReader("yaml", strings.NewReader(content))
+	config.SetEncryptionKey(key)
+
+	// Act
+	actual, err := config.Sub("encryption").GetStringE("user")
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "failed to decrypt key [encryption.user]")
+	assert.Empty(actual)
+}
+
+func TestConfigurationGetStringSlice_WithEncryptedItems_DecryptsItems(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key := createEncryptionKey()
+	first, _ := EncryptValue(key, "encryption.hosts.0", "first")
+	second, _ := EncryptValue(key, "encryption.hosts.1", "second")
+	content := "encryption:\n  hosts:\n    - " + first + "\n    - " + second + "\n"
+	config, _ := NewConfigurationFromReader("yaml", strings.NewReader(content))
+	config.SetEncryptionKey(key)
+
+	// Act
+	actual := config.GetStringSlice("encryption.hosts")
+
+	// Assert
+	assert.Equal([]string{"first", "second"}, actual)
+}
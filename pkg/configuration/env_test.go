@@ -0,0 +1,113 @@
+package conf
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type EnvTestUpstream struct {
+	URL      string `configkey:"url"`
+	Priority int    `configkey:"priority" default:"1"`
+}
+
+type EnvTestConfig struct {
+	Upstreams map[string]EnvTestUpstream `configkey:"envtest.upstreams"`
+	Limits    map[string]int             `configkey:"envtest.limits"`
+	Nested    struct {
+		Value string `configkey:"value"`
+	} `configkey:"envtest.nested"`
+}
+
+func setEnv(t *testing.T, values map[string]string) {
+	for name, value := range values {
+		os.Setenv(name, value)
+	}
+	t.Cleanup(func() {
+		for name := range values {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+func createEnvConfiguration(t *testing.T, content string) *Configuration {
+	reader, err := NewReaderSource("base", "yaml", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("reading configuration failed: %s", err)
+	}
+	config := NewConfiguration("envtest", "", nil)
+	config.AddSource(reader, NewEnvSource("app"))
+	if err := config.Initialize(); err != nil {
+		t.Fatalf("reading configuration failed: %s", err)
+	}
+	return config
+}
+
+func TestConfigurationInitializeComponentConfig_WithEnvVariables_OverridesAndAddsEntries(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setEnv(t, map[string]string{
+		"APP_ENVTEST_UPSTREAMS_MAIN_URL":             "http://main.env",
+		"APP_ENVTEST_UPSTREAMS_BACKUP_NODE_URL":      "http://backup.env",
+		"APP_ENVTEST_UPSTREAMS_BACKUP_NODE_PRIORITY": "2",
+		"APP_ENVTEST_LIMITS_WRITE":                   "5",
+		"APP_ENVTEST_NESTED_VALUE":                   "env",
+	})
+	config := createEnvConfiguration(t, `
+envtest:
+  upstreams:
+    main:
+      url: http://main.file
+  limits:
+    read: 1
+`)
+	envConfig := &EnvTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(envConfig)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(map[string]EnvTestUpstream{
+		"main":        {URL: "http://main.env", Priority: 1},
+		"backup_node": {URL: "http://backup.env", Priority: 2},
+	}, envConfig.Upstreams)
+	assert.Equal(map[string]int{"read": 1, "write": 5}, envConfig.Limits)
+	assert.Equal("env", envConfig.Nested.Value)
+}
+
+func TestConfigurationSub_WithEnvVariablesOnly_ReturnsSubConfiguration(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setEnv(t, map[string]string{"APP_ENVTEST_SUB_PORT": "8080"})
+	config := createEnvConfiguration(t, "envtest:\n  other: true\n")
+
+	// Act
+	subConfig := config.Sub("envtest").Sub("sub")
+
+	// Assert
+	if assert.NotNil(subConfig) {
+		assert.Equal(8080, subConfig.GetInt("port"))
+		assert.Equal("env", subConfig.SourceOf("port"))
+	}
+}
+
+func TestConfigurationGetStringMap_WithNestedEnvVariable_ReturnsOverriddenValue(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setEnv(t, map[string]string{"CORE_UNITTEST_STRING_MAP_K2_VALUE": "true"})
+	config := createConfiguration(t)
+
+	// Act
+	actual, err := config.GetStringMap("unittest.string_map", reflect.TypeOf(NestedTestConfig{}))
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(map[string]NestedTestConfig{
+		"k1": {Value: true},
+		"k2": {Value: true},
+	}, actual)
+}
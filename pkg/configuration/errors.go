@@ -0,0 +1,113 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// FieldError describes an invalid value of a configuration key.
+type FieldError struct {
+	// Key is the full path of the configuration key, e.g. "database.port".
+	Key string
+	// Source is the name of the source which provided the value (see
+	// Configuration.SourceOf), empty if the key has no value.
+	Source string
+	// Reason explains why the value is invalid.
+	Reason string
+}
+
+func (e FieldError) Error() string {
+	source := e.Source
+	if source == "" {
+		source = "unset"
+	}
+	return fmt.Sprintf("key [%s] (source: %s): %s", e.Key, source, e.Reason)
+}
+
+// ConfigError is returned by InitializeComponentConfig and lists all the
+// invalid values of a component configuration, the ones of its nested
+// configurations included.
+type ConfigError struct {
+	Errors []FieldError
+}
+
+func (e *ConfigError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldError := range e.Errors {
+		messages[i] = fieldError.Error()
+	}
+	return "invalid configuration: " + strings.Join(messages, "; ")
+}
+
+// HasKey returns whether an error was reported for the given key or one of
+// its children.
+func (e *ConfigError) HasKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, fieldError := range e.Errors {
+		errorKey := strings.ToLower(fieldError.Key)
+		if errorKey == key || strings.HasPrefix(errorKey, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// add records err as an error of the given key. The errors of a nested
+// ConfigError are recorded as they are, their keys being already complete.
+func (e *ConfigError) add(c *Configuration, key string, err error) {
+	var nested *ConfigError
+	if errors.As(err, &nested) {
+		e.Errors = append(e.Errors, nested.Errors...)
+		return
+	}
+	e.Errors = append(e.Errors, c.fieldError(key, err.Error()))
+}
+
+// addValidationErrors records the errors returned by the validation of a
+// component configuration, the keys being given by the configkey tags of its
+// fields. The errors of the fields listed in skipped are ignored.
+func (e *ConfigError) addValidationErrors(c *Configuration, validationErrors validator.ValidationErrors, keys map[string]string, skipped map[string]bool) {
+	for _, validationError := range validationErrors {
+		// The namespace starts with the name of the validated struct type.
+		path := strings.SplitN(validationError.StructNamespace(), ".", 3)
+		if len(path) < 2 || skipped[path[1]] {
+			continue
+		}
+		reason := validationReason(validationError)
+		key, ok := keys[path[1]]
+		if !ok {
+			key = strings.ToLower(path[1])
+		}
+		if len(path) > 2 {
+			reason = fmt.Sprintf("field %s: %s", path[2], reason)
+		}
+		e.Errors = append(e.Errors, c.fieldError(key, reason))
+	}
+}
+
+// fieldError returns the error of the given key with its full path and the
+// source of its value.
+func (c *Configuration) fieldError(key, reason string) FieldError {
+	return FieldError{
+		Key:    c.prefix + key,
+		Source: c.SourceOf(key),
+		Reason: reason,
+	}
+}
+
+// validationReason returns a human readable description of a failed
+// validation rule. The value is never part of the description as it may be a
+// secret.
+func validationReason(validationError validator.FieldError) string {
+	if validationError.Tag() == "required" {
+		return "value is required"
+	}
+	rule := validationError.Tag()
+	if validationError.Param() != "" {
+		rule += "=" + validationError.Param()
+	}
+	return fmt.Sprintf("value does not satisfy the [%s] validation rule", rule)
+}
@@ -0,0 +1,172 @@
+package conf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type ErrorsTestNested struct {
+	Port int `configkey:"port" validate:"min=1024"`
+}
+
+type ErrorsTestConfig struct {
+	Name     string                      `configkey:"errors.name" validate:"required"`
+	Count    uint8                       `configkey:"errors.count"`
+	Password string                      `configkey:"errors.password,secret" validate:"min=8"`
+	Nested   ErrorsTestNested            `configkey:"errors.nested"`
+	Entries  map[string]ErrorsTestNested `configkey:"errors.entries"`
+}
+
+const errorsTestContent = `
+errors:
+  count: 300
+  password: short
+  nested:
+    port: 80
+  entries:
+    first:
+      port: 8080
+    second:
+      port: 25
+`
+
+func TestConfigurationInitializeComponentConfig_WithInvalidValues_ReturnsAllErrors(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, errorsTestContent)
+	errorsConfig := &ErrorsTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(errorsConfig)
+
+	// Assert
+	var configErr *ConfigError
+	if assert.True(errors.As(err, &configErr)) {
+		keys := make([]string, len(configErr.Errors))
+		for i, fieldError := range configErr.Errors {
+			keys[i] = fieldError.Key
+			if fieldError.Key != "errors.name" {
+				assert.Equal("reader:yaml", fieldError.Source, fieldError.Key)
+			}
+		}
+		assert.ElementsMatch([]string{
+			"errors.name",
+			"errors.count",
+			"errors.password",
+			"errors.nested.port",
+			"errors.entries.second.port",
+		}, keys)
+		assert.True(configErr.HasKey("errors.entries"))
+		assert.False(configErr.HasKey("errors.entries.first"))
+	}
+}
+
+func TestConfigurationInitializeComponentConfig_WithInvalidValues_ReportsUnsetKeys(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, errorsTestContent)
+	errorsConfig := &ErrorsTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(errorsConfig)
+
+	// Assert
+	var configErr *ConfigError
+	if assert.True(errors.As(err, &configErr)) {
+		for _, fieldError := range configErr.Errors {
+			if fieldError.Key == "errors.name" {
+				assert.Equal("", fieldError.Source)
+				assert.Equal("value is required", fieldError.Reason)
+			}
+		}
+		assert.Contains(err.Error(), "key [errors.name] (source: unset): value is required")
+	}
+}
+
+func TestConfigurationInitializeComponentConfig_WithInvalidSecret_DoesNotReturnValue(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, errorsTestContent)
+	errorsConfig := &ErrorsTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(errorsConfig)
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "key [errors.password] (source: reader:yaml): value does not satisfy the [min=8] validation rule")
+	assert.NotContains(err.Error(), "short")
+}
+
+type ErrorsTestCollections struct {
+	Limits  map[string]int     `configkey:"errors.limits"`
+	Ports   []int              `configkey:"errors.ports"`
+	Codes   []int              `configkey:"errors.codes"`
+	Servers []ErrorsTestNested `configkey:"errors.servers"`
+}
+
+const errorsTestCollectionsContent = `
+errors:
+  limits:
+    a: secret-a
+    b: 2
+    c: secret-c
+  ports: [1, secret-port-1, secret-port-2]
+  codes: "1, secret-code"
+  servers:
+    - port: 25
+    - port: 8080
+    - port: 80
+`
+
+func TestConfigurationInitializeComponentConfig_WithInvalidEntriesAndItems_ReturnsAllErrors(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, errorsTestCollectionsContent)
+	collections := &ErrorsTestCollections{}
+
+	// Act
+	err := config.InitializeComponentConfig(collections)
+
+	// Assert
+	var configErr *ConfigError
+	if assert.True(errors.As(err, &configErr)) {
+		keys := make([]string, len(configErr.Errors))
+		for i, fieldError := range configErr.Errors {
+			keys[i] = fieldError.Key
+			assert.Equal("reader:yaml", fieldError.Source, fieldError.Key)
+		}
+		assert.ElementsMatch([]string{
+			"errors.limits.a",
+			"errors.limits.c",
+			"errors.ports.1",
+			"errors.ports.2",
+			"errors.codes.1",
+			"errors.servers.0.port",
+			"errors.servers.2.port",
+		}, keys)
+		assert.Contains(err.Error(), "key [errors.ports.1] (source: reader:yaml): failed to decode key [errors.ports.1] as int")
+		assert.NotContains(err.Error(), "secret")
+	}
+}
+
+func TestConfigurationGetStringMap_WithInvalidEntries_ReturnsAllErrors(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, errorsTestCollectionsContent)
+
+	// Act
+	_, err := config.GetStringMap("errors.limits", reflect.TypeOf(0))
+
+	// Assert
+	var configErr *ConfigError
+	if assert.True(errors.As(err, &configErr)) {
+		assert.Equal([]FieldError{
+			{Key: "errors.limits.a", Source: "reader:yaml", Reason: "failed to decode key [errors.limits.a] as int"},
+			{Key: "errors.limits.c", Source: "reader:yaml", Reason: "failed to decode key [errors.limits.c] as int"},
+		}, configErr.Errors)
+	}
+}
@@ -0,0 +1,139 @@
+package conf
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Get returns the value associated with the given key converted to T, T being
+// any type supported by InitializeComponentConfig. The zero value of T is
+// returned if the value cannot be converted, see GetE.
+// Example:
+//	port := conf.Get[uint16](c, "server.port")
+func Get[T any](c *Configuration, key string) T {
+	res, _ := GetE[T](c, key)
+	return res
+}
+
+// GetE returns the value associated with the given key converted to T, T
+// being any type supported by InitializeComponentConfig, or an error if the
+// value cannot be converted. The zero value of T is returned if the key is not
+// set, except for structs and time.Time which require a value.
+func GetE[T any](c *Configuration, key string) (T, error) {
+	c.ensureInitialized()
+	var res T
+	if err := c.bindField(reflect.ValueOf(&res).Elem(), key, nil); err != nil {
+		var zero T
+		return zero, err
+	}
+	return res, nil
+}
+
+// GetMap returns the values associated with the given key as a map of T (see
+// GetStringMap).
+func GetMap[T any](c *Configuration, key string) (map[string]T, error) {
+	value, err := c.GetStringMap(key, reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	return value.(map[string]T), nil
+}
+
+// Bind returns a new component configuration of type T initialized with
+// InitializeComponentConfig.
+// Example:
+//	ormConfig, err := conf.Bind[orm.Config](c)
+func Bind[T any](c *Configuration) (*T, error) {
+	res := new(T)
+	if err := c.InitializeComponentConfig(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// MustBind is like Bind but panics if the component configuration cannot be
+// initialized.
+func MustBind[T any](c *Configuration) *T {
+	res, err := Bind[T](c)
+	if err != nil {
+		panic(errors.Wrapf(err, "failed to bind %T", res))
+	}
+	return res
+}
+
+// GetIntE returns the value associated with the given key as an integer, or
+// an error if it cannot be converted.
+func (c *Configuration) GetIntE(key string) (int, error) {
+	return GetE[int](c, key)
+}
+
+// GetStringE returns the value associated with the given key as a string, or
+// an error if it cannot be converted.
+func (c *Configuration) GetStringE(key string) (string, error) {
+	return GetE[string](c, key)
+}
+
+// GetStringSliceE returns the value associated with the given key as a string
+// slice, or an error if it cannot be converted.
+func (c *Configuration) GetStringSliceE(key string) ([]string, error) {
+	return GetE[[]string](c, key)
+}
+
+// GetBoolE returns the value associated with the given key as a boolean, or
+// an error if it cannot be converted.
+func (c *Configuration) GetBoolE(key string) (bool, error) {
+	return GetE[bool](c, key)
+}
+
+// GetDurationE returns the value associated with the given key as a Duration,
+// or an error if it does not match the Duration format.
+func (c *Configuration) GetDurationE(key string, isISO8601 bool) (time.Duration, error) {
+	c.ensureInitialized()
+	options := []string{durationTagValue}
+	if isISO8601 {
+		options = append(options, iso8601TagValue)
+	}
+	var res time.Duration
+	if err := c.bindField(reflect.ValueOf(&res).Elem(), key, options); err != nil {
+		return 0, err
+	}
+	return res, nil
+}
+
+// GetFloat32E returns the value associated with the given key as a float32,
+// or an error if it cannot be converted.
+func (c *Configuration) GetFloat32E(key string) (float32, error) {
+	return GetE[float32](c, key)
+}
+
+// GetFloat64E returns the value associated with the given key as a float64,
+// or an error if it cannot be converted.
+func (c *Configuration) GetFloat64E(key string) (float64, error) {
+	return GetE[float64](c, key)
+}
+
+// GetInt64E returns the value associated with the given key as a int64, or an
+// error if it cannot be converted.
+func (c *Configuration) GetInt64E(key string) (int64, error) {
+	return GetE[int64](c, key)
+}
+
+// GetUInt8E returns the value associated with the given key as a uint8, or an
+// error if it cannot be converted or overflows.
+func (c *Configuration) GetUInt8E(key string) (uint8, error) {
+	return GetE[uint8](c, key)
+}
+
+// GetUInt32E returns the value associated with the given key as a uint32, or
+// an error if it cannot be converted or overflows.
+func (c *Configuration) GetUInt32E(key string) (uint32, error) {
+	return GetE[uint32](c, key)
+}
+
+// GetUInt64E returns the value associated with the given key as a uint64, or
+// an error if it cannot be converted.
+func (c *Configuration) GetUInt64E(key string) (uint64, error) {
+	return GetE[uint64](c, key)
+}
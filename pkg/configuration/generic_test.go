@@ -0,0 +1,140 @@
+package conf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const genericTestContent = `
+generic:
+  port: 8080
+  big: 70000
+  timeout: 1m
+  iso: PT1H30M
+  invalid_iso: one day
+  names: [a, b]
+  nested:
+    value: true
+  limits:
+    read: 1
+    write: 2
+`
+
+func TestGet_WithTypedKeys_ReturnsValues(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, genericTestContent)
+
+	// Act
+	port := Get[uint16](config, "generic.port")
+	timeout := Get[time.Duration](config, "generic.timeout")
+	names := Get[[]string](config, "generic.names")
+	nested := Get[NestedTestConfig](config, "generic.nested")
+	unset := Get[*int](config, "generic.unset")
+	overflow := Get[uint16](config, "generic.big")
+
+	// Assert
+	assert.Equal(uint16(8080), port)
+	assert.Equal(time.Minute, timeout)
+	assert.Equal([]string{"a", "b"}, names)
+	assert.Equal(NestedTestConfig{Value: true}, nested)
+	assert.Nil(unset)
+	assert.Equal(uint16(0), overflow)
+}
+
+func TestGetE_WithOverflow_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, genericTestContent)
+
+	// Act
+	_, err := GetE[uint16](config, "generic.big")
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestGetMap_WithIntValues_ReturnsMap(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, genericTestContent)
+
+	// Act
+	actual, err := GetMap[int](config, "generic.limits")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(map[string]int{"read": 1, "write": 2}, actual)
+}
+
+func TestBind_WithComponentConfig_ReturnsInitializedConfig(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, "decode:\n  i32: 32\n")
+
+	// Act
+	actual, err := Bind[DecodeTestConfig](config)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(int32(32), actual.I32)
+}
+
+func TestMustBind_WithInvalidConfig_Panics(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, "decode:\n  ui16: 70000\n")
+
+	// Act
+	act := func() { MustBind[DecodeTestConfig](config) }
+
+	// Assert
+	assert.Panics(act)
+}
+
+func TestConfigurationGetDurationE_WithInvalidISO8601_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, genericTestContent)
+
+	// Act
+	valid, validErr := config.GetDurationE("generic.iso", true)
+	_, invalidErr := config.GetDurationE("generic.invalid_iso", true)
+
+	// Assert
+	assert.NoError(validErr)
+	assert.Equal(90*time.Minute, valid)
+	assert.Error(invalidErr)
+	assert.Contains(invalidErr.Error(), "generic.invalid_iso")
+	assert.NotContains(invalidErr.Error(), "one day")
+	assert.Equal(time.Duration(0), config.GetDuration("generic.invalid_iso", true))
+}
+
+func TestConfigurationGetDurationE_WithInvalidDuration_DoesNotReturnValue(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, "generic:\n  timeout: s3cr3t\n")
+
+	// Act
+	_, err := config.GetDurationE("generic.timeout", false)
+
+	// Assert
+	assert.EqualError(err, "failed to decode key [generic.timeout] as duration")
+}
+
+func TestConfigurationGetIntE_WithInvalidValue_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, genericTestContent)
+
+	// Act
+	valid, validErr := config.GetIntE("generic.port")
+	_, invalidErr := config.GetIntE("generic.names")
+
+	// Assert
+	assert.NoError(validErr)
+	assert.Equal(8080, valid)
+	assert.Error(invalidErr)
+}
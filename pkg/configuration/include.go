@@ -0,0 +1,127 @@
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+// includeKey is the key of the include directive.
+const includeKey = "include"
+
+// resolveIncludes merges the files listed by the include directive of values
+// (a path or a list of paths, glob patterns allowed) with values. Relative
+// paths are relative to dir, the directory of the including file. The files
+// are merged in the order they are listed, the files matching a pattern in
+// lexical order, and the values of the including file override the included
+// values. Included files can include other files, stack holding the files
+// being included to detect cycles. The returned patterns are the absolute
+// paths and patterns of all the included files, nested ones included.
+func resolveIncludes(values map[string]interface{}, dir string, stack []string) (map[string]interface{}, []string, error) {
+	raw, ok := values[includeKey]
+	if !ok {
+		return values, nil, nil
+	}
+	delete(values, includeKey)
+	includes, err := cast.ToStringSliceE(raw)
+	if err != nil {
+		return nil, nil, errors.Errorf("include directive of %s must be a path or a list of paths", includingName(stack))
+	}
+
+	merged := map[string]interface{}{}
+	var patterns []string
+	for _, include := range includes {
+		pattern := include
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		pattern, err = filepath.Abs(pattern)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid include [%s] in %s", include, includingName(stack))
+		}
+		patterns = append(patterns, pattern)
+
+		paths, err := includedPaths(pattern)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid include [%s] in %s", include, includingName(stack))
+		}
+		if len(paths) == 0 && !hasGlobMeta(include) {
+			return nil, nil, errors.Errorf("included file [%s] not found (included by %s)", pattern, includingName(stack))
+		}
+		for _, path := range paths {
+			for _, p := range stack {
+				if p == path {
+					return nil, nil, errors.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), path)
+				}
+			}
+			included, nested, err := loadIncludedFile(path, append(stack, path))
+			if err != nil {
+				return nil, nil, err
+			}
+			patterns = append(patterns, nested...)
+			mergeValues(merged, included)
+		}
+	}
+	mergeValues(merged, values)
+	return merged, patterns, nil
+}
+
+// loadIncludedFile reads an included file and the files it includes.
+func loadIncludedFile(path string, stack []string) (map[string]interface{}, []string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to read included file [%s]", path)
+	}
+	values, err := readValues(strings.TrimPrefix(filepath.Ext(path), "."), content)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to parse included file [%s]", path)
+	}
+	return resolveIncludes(values, filepath.Dir(path), stack)
+}
+
+// includedPaths returns the sorted regular files matching the pattern.
+func includedPaths(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			res = append(res, match)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// mergeValues merges src into dst, nested maps being merged key by key.
+func mergeValues(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if srcMap, ok := toStringMap(value); ok {
+			if dstMap, ok := toStringMap(dst[key]); ok {
+				merged := copyValue(dstMap).(map[string]interface{})
+				mergeValues(merged, srcMap)
+				dst[key] = merged
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+func includingName(stack []string) string {
+	if len(stack) == 0 {
+		return "reader"
+	}
+	return "[" + stack[len(stack)-1] + "]"
+}
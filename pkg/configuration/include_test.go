@@ -0,0 +1,155 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createIncludeDir(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("creating directory failed: %s", err)
+		}
+		writeConfigFile(t, path, content)
+	}
+	return dir
+}
+
+func TestConfigurationInitialize_WithIncludes_MergesFilesInOrder(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := createIncludeDir(t, map[string]string{
+		"app.yml":           "include:\n  - common.yml\n  - conf.d/*.yml\nservice:\n  name: app\n",
+		"common.yml":        "service:\n  name: common\n  port: 80\n  host: localhost\n",
+		"conf.d/10-db.yml":  "database:\n  host: db\nservice:\n  port: 8080\n",
+		"conf.d/20-log.yml": "log:\n  level: debug\nservice:\n  port: 9090\n",
+	})
+	config := NewConfiguration("app", "app", []string{dir})
+
+	// Act
+	err := config.Initialize()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("app", config.GetString("service.name"))
+	assert.Equal(9090, config.GetInt("service.port"))
+	assert.Equal("localhost", config.GetString("service.host"))
+	assert.Equal("db", config.GetString("database.host"))
+	assert.Equal("debug", config.GetString("log.level"))
+	assert.Empty(config.GetString("include"))
+}
+
+func TestConfigurationInitialize_WithNestedInclude_ResolvesRelativeToIncludingFile(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := createIncludeDir(t, map[string]string{
+		"app.yml":           "include: shared/base.yml\n",
+		"shared/base.yml":   "include: [values.yml]\nbase: true\n",
+		"shared/values.yml": "value: 1\n",
+	})
+	config := NewConfiguration("app", "app", []string{dir})
+
+	// Act
+	err := config.Initialize()
+
+	// Assert
+	assert.NoError(err)
+	assert.True(config.GetBool("base"))
+	assert.Equal(1, config.GetInt("value"))
+}
+
+func TestConfigurationInitialize_WithIncludeCycle_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := createIncludeDir(t, map[string]string{
+		"app.yml": "include: a.yml\n",
+		"a.yml":   "include: b.yml\n",
+		"b.yml":   "include: a.yml\n",
+	})
+	config := NewConfiguration("app", "app", []string{dir})
+
+	// Act
+	err := config.Initialize()
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "include cycle")
+	assert.Contains(err.Error(), filepath.Join(dir, "a.yml")+" -> "+filepath.Join(dir, "b.yml")+" -> "+filepath.Join(dir, "a.yml"))
+}
+
+func TestConfigurationInitialize_WithMissingInclude_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := createIncludeDir(t, map[string]string{
+		"app.yml": "include:\n  - missing.yml\n",
+	})
+	config := NewConfiguration("app", "app", []string{dir})
+
+	// Act
+	err := config.Initialize()
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), filepath.Join(dir, "missing.yml"))
+	assert.Contains(err.Error(), filepath.Join(dir, "app.yml"))
+}
+
+func TestConfigurationInitialize_WithUnmatchedPattern_IgnoresPattern(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := createIncludeDir(t, map[string]string{
+		"app.yml": "include: conf.d/*.yml\nvalue: 1\n",
+	})
+	config := NewConfiguration("app", "app", []string{dir})
+
+	// Act
+	err := config.Initialize()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(1, config.GetInt("value"))
+}
+
+func TestNewConfigurationFromReader_WithInclude_ReadsIncludedFile(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := createIncludeDir(t, map[string]string{
+		"common.yml": "value: 1\nname: common\n",
+	})
+	content := "include: " + filepath.Join(dir, "common.yml") + "\nname: reader\n"
+
+	// Act
+	config, err := NewConfigurationFromReader("yaml", strings.NewReader(content))
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(1, config.GetInt("value"))
+	assert.Equal("reader", config.GetString("name"))
+}
+
+func TestConfigurationReload_WithChangedIncludedFile_ReadsNewValues(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := createIncludeDir(t, map[string]string{
+		"app.yml":    "include: common.yml\n",
+		"common.yml": "value: 1\n",
+	})
+	config := NewConfiguration("app", "app", []string{dir})
+	config.Initialize()
+	source := config.sources[0].(*FileSource)
+	writeConfigFile(t, filepath.Join(dir, "common.yml"), "value: 2\n")
+
+	// Act
+	err := config.Reload()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(2, config.GetInt("value"))
+	assert.True(source.matches(filepath.Join(dir, "common.yml")))
+}
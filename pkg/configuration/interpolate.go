@@ -0,0 +1,146 @@
+package conf
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+// secretReferencePrefix is the prefix of the interpolated secret references.
+const secretReferencePrefix = "secret:"
+
+// envNameRegexp matches the names of the interpolated environment variables.
+var envNameRegexp = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+
+// interpolate replaces the ${...} expressions contained in value (string or
+// slice) read for the given key:
+//	- ${other.key} is replaced by the value of the key other.key, the keys
+//	  being always given by their full path,
+//	- ${ENV_VAR} is replaced by the value of the environment variable ENV_VAR,
+//	  names made of upper case letters, digits and underscores being
+//	  environment variables,
+//	- ${name:-default} is replaced by default if the key or the environment
+//	  variable is not set or empty,
+//	- ${secret:<scheme>:<reference>} is replaced by the secret returned by the
+//	  resolver registered for the scheme (see SecretResolver),
+//	- $${ is replaced by ${.
+// A value made of a single key reference takes the value of the key as it is,
+// e.g. a number. Interpolations are resolved each time a value is read, stack
+// holding the keys being resolved to detect cycles.
+func (c *Configuration) interpolate(key string, value interface{}, stack []string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return c.interpolateString(key, v, stack)
+	case []string:
+		res := make([]string, len(v))
+		for i, s := range v {
+			resolved, err := c.interpolateString(key, s, stack)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = cast.ToString(resolved)
+		}
+		return res, nil
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			resolved, err := c.interpolate(key, e, stack)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = resolved
+		}
+		return res, nil
+	}
+	return value, nil
+}
+
+func (c *Configuration) interpolateString(key, value string, stack []string) (interface{}, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		if strings.HasPrefix(value[i:], "$${") {
+			b.WriteString("${")
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(value[i:], "${") {
+			b.WriteByte(value[i])
+			i++
+			continue
+		}
+		end := strings.Index(value[i:], "}")
+		if end < 0 {
+			return nil, errors.Errorf("unterminated interpolation in key [%s]", key)
+		}
+		end += i
+		resolved, err := c.resolveExpression(key, value[i+2:end], stack)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 && end == len(value)-1 {
+			return resolved, nil
+		}
+		b.WriteString(cast.ToString(resolved))
+		i = end + 1
+	}
+	return b.String(), nil
+}
+
+// resolveExpression returns the value of an interpolation expression, the
+// content of ${...}.
+func (c *Configuration) resolveExpression(key, expression string, stack []string) (interface{}, error) {
+	if strings.HasPrefix(expression, secretReferencePrefix) {
+		return resolveSecret(c.getResolvers(), key, strings.TrimPrefix(expression, secretReferencePrefix))
+	}
+	name, defaultValue, hasDefault := expression, "", false
+	if i := strings.Index(expression, ":-"); i >= 0 {
+		name, defaultValue, hasDefault = expression[:i], expression[i+2:], true
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.Errorf("empty interpolation in key [%s]", key)
+	}
+
+	if envNameRegexp.MatchString(name) {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			return value, nil
+		}
+		if hasDefault {
+			return defaultValue, nil
+		}
+		return nil, errors.Errorf("environment variable [%s] interpolated in key [%s] is not set", name, key)
+	}
+
+	reference := strings.ToLower(name)
+	for _, k := range stack {
+		if k == reference {
+			return nil, errors.Errorf("interpolation cycle: %s -> %s", strings.Join(stack, " -> "), reference)
+		}
+	}
+	c.usedKeys.add(reference)
+	value := c.rawValue(reference)
+	if value == nil || value == "" {
+		if hasDefault {
+			return defaultValue, nil
+		}
+		return nil, errors.Errorf("key [%s] interpolated in key [%s] is not set", reference, key)
+	}
+	return c.interpolate(reference, value, append(stack, reference))
+}
+
+// rawValue returns the value of the key with the given full path, before
+// interpolation and secret resolution.
+func (c *Configuration) rawValue(path string) interface{} {
+	value := c.getViper().Get(path)
+	if value == nil {
+		// The defaults of the items of lists are shadowed by the lists.
+		value = c.defaults.get(strings.ToLower(path))
+	}
+	return value
+}
@@ -0,0 +1,99 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const interpolateTestContent = `
+server:
+  host: example.com
+  port: 8080
+  address: ${server.host}:${server.port}
+  url: http://${server.address}/api
+  port_copy: ${server.port}
+  escaped: $${server.host}
+  hosts: ["${server.host}", other.com]
+database:
+  host: ${INTERPOLATE_TEST_DB_HOST:-localhost}
+  user: ${INTERPOLATE_TEST_DB_USER:-postgres}
+  name: ${database.missing:-db}
+cycle:
+  a: ${cycle.b}
+  b: x${cycle.a}
+missing:
+  key: ${missing.other}
+  env: ${INTERPOLATE_TEST_MISSING}
+`
+
+type InterpolateTestConfig struct {
+	Address string   `configkey:"server.address"`
+	Port    int      `configkey:"server.port_copy"`
+	Hosts   []string `configkey:"server.hosts"`
+	Timeout string   `configkey:"server.timeout" default:"${server.port}s"`
+}
+
+func TestConfigurationGetString_WithInterpolation_ReturnsResolvedValue(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	setEnv(t, map[string]string{"INTERPOLATE_TEST_DB_HOST": "db.env"})
+	config := createDecodeConfiguration(t, interpolateTestContent)
+
+	// Act & Assert
+	assert.Equal("example.com:8080", config.GetString("server.address"))
+	assert.Equal("http://example.com:8080/api", config.GetString("server.url"))
+	assert.Equal("${server.host}", config.GetString("server.escaped"))
+	assert.Equal("db.env", config.GetString("database.host"))
+	assert.Equal("postgres", config.GetString("database.user"))
+	assert.Equal("db", config.GetString("database.name"))
+	assert.Equal("example.com:8080", config.Sub("server").GetString("address"))
+}
+
+func TestConfigurationInitializeComponentConfig_WithInterpolation_ReturnsResolvedValues(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, interpolateTestContent)
+	interpolateConfig := &InterpolateTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(interpolateConfig)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(InterpolateTestConfig{
+		Address: "example.com:8080",
+		Port:    8080,
+		Hosts:   []string{"example.com", "other.com"},
+		Timeout: "8080s",
+	}, *interpolateConfig)
+}
+
+func TestConfigurationGetStringE_WithCycle_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, interpolateTestContent)
+
+	// Act
+	_, err := config.GetStringE("cycle.a")
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "interpolation cycle: cycle.a -> cycle.b -> cycle.a")
+}
+
+func TestConfigurationGetStringE_WithMissingReference_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, interpolateTestContent)
+
+	// Act
+	_, keyErr := config.GetStringE("missing.key")
+	_, envErr := config.GetStringE("missing.env")
+
+	// Assert
+	assert.Error(keyErr)
+	assert.Contains(keyErr.Error(), "key [missing.other] interpolated in key [missing.key] is not set")
+	assert.Error(envErr)
+	assert.Contains(envErr.Error(), "environment variable [INTERPOLATE_TEST_MISSING]")
+}
@@ -0,0 +1,286 @@
+package conf
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	descTagName     = "desc"
+	validateTagName = "validate"
+	exampleTagName  = "example"
+
+	// MapKeyPlaceholder is the segment standing for the entry names of the
+	// maps in the keys of the reference entries.
+	MapKeyPlaceholder = "<name>"
+	// ListItemPlaceholder is the segment standing for the items of the lists
+	// in the keys of the reference entries.
+	ListItemPlaceholder = "[]"
+)
+
+// ReferenceEntry describes a configuration key of a component configuration.
+type ReferenceEntry struct {
+	// Key is the full path of the key. The entries of maps and the items of
+	// lists of structs are given by MapKeyPlaceholder and ListItemPlaceholder.
+	Key         string
+	Type        string
+	Default     string
+	Validation  string
+	Description string
+	Secret      bool
+	// Example is the value written by WriteReferenceYAML instead of the
+	// default value, given by the example tag.
+	Example string
+}
+
+// Reference returns the keys of the given component configurations (structs
+// or pointers to structs) as documented by the tags of their fields: the
+// configkey, default, validate, desc and example tags. Nested structs, maps and lists
+// of structs are described key by key.
+// Example:
+//	type Config struct {
+//		Port int `configkey:"server.port" default:"8080" validate:"min=1" desc:"Port to listen on"`
+//	}
+func Reference(compConfs ...interface{}) ([]ReferenceEntry, error) {
+	var entries []ReferenceEntry
+	for _, compConf := range compConfs {
+		t := reflect.TypeOf(compConf)
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			return nil, errors.Errorf("component configuration must be a struct, got %T", compConf)
+		}
+		entries = appendReferenceEntries(entries, "", t)
+	}
+	return entries, nil
+}
+
+func appendReferenceEntries(entries []ReferenceEntry, prefix string, t reflect.Type) []ReferenceEntry {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tagValues, isSecret := parseConfigTag(field.Tag.Get(configTagName))
+		if tagValues[0] == "" {
+			continue
+		}
+		key := prefix + tagValues[0]
+		fieldType := field.Type
+		switch {
+		case isStructType(fieldType):
+			entries = appendReferenceEntries(entries, key+".", indirectType(fieldType))
+			continue
+		case fieldType.Kind() == reflect.Map && isStructType(fieldType.Elem()):
+			entries = appendReferenceEntries(entries, key+"."+MapKeyPlaceholder+".", indirectType(fieldType.Elem()))
+			continue
+		case fieldType.Kind() == reflect.Slice && isStructType(fieldType.Elem()):
+			entries = appendReferenceEntries(entries, key+"."+ListItemPlaceholder+".", indirectType(fieldType.Elem()))
+			continue
+		}
+		entries = append(entries, ReferenceEntry{
+			Key:         key,
+			Type:        referenceTypeName(fieldType, tagValues[1:]),
+			Default:     field.Tag.Get(defaultTagName),
+			Validation:  field.Tag.Get(validateTagName),
+			Description: field.Tag.Get(descTagName),
+			Secret:      isSecret,
+			Example:     field.Tag.Get(exampleTagName),
+		})
+	}
+	return entries
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// referenceTypeName returns the name of the type of a key as documented in
+// the reference.
+func referenceTypeName(t reflect.Type, options []string) string {
+	switch {
+	case t == durationType || (t.Kind() == reflect.Int64 && hasOption(options, durationTagValue)):
+		if hasOption(options, iso8601TagValue) {
+			return "duration (ISO8601)"
+		}
+		return "duration"
+	case t == timeType:
+		return "time (RFC3339)"
+	case t == byteArrayType:
+		if len(options) > 0 {
+			return "bytes (" + options[0] + ")"
+		}
+		return "bytes (" + utf8TagValue + ")"
+	}
+	return t.String()
+}
+
+// WriteReferenceYAML writes a YAML configuration file skeleton of the given
+// entries, each key being preceded by comments giving its description, type
+// and validation rules, and set to its example value if any or else to its
+// default value.
+func WriteReferenceYAML(w io.Writer, entries []ReferenceEntry) error {
+	root := &referenceNode{}
+	for i := range entries {
+		root.add(strings.Split(entries[i].Key, "."), &entries[i])
+	}
+	var b strings.Builder
+	root.writeYAML(&b, "", "")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteReferenceEnv writes the environment variables overriding the keys of
+// the given entries, for an application with the given name, as a dotenv file
+// setting the variables to their default values.
+func WriteReferenceEnv(w io.Writer, appName string, entries []ReferenceEntry) error {
+	env := &EnvSource{Prefix: appName}
+	var b strings.Builder
+	for _, entry := range entries {
+		for _, comment := range entry.comments() {
+			fmt.Fprintf(&b, "# %s\n", comment)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", env.VariableName(entry.Key), entry.Default)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteReferenceMarkdown writes a Markdown table documenting the given entries
+// and the environment variables overriding them, for an application with the
+// given name.
+func WriteReferenceMarkdown(w io.Writer, appName string, entries []ReferenceEntry) error {
+	env := &EnvSource{Prefix: appName}
+	var b strings.Builder
+	b.WriteString("| Key | Environment variable | Type | Default | Validation | Description |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, entry := range entries {
+		description := entry.Description
+		if entry.Secret {
+			description = strings.TrimSpace(description + " (secret)")
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n",
+			markdownCode(entry.Key),
+			markdownCode(env.VariableName(entry.Key)),
+			markdownEscape(entry.Type),
+			markdownCode(entry.Default),
+			markdownCode(entry.Validation),
+			markdownEscape(description))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// comments returns the description, type and validation rules of the entry.
+func (e *ReferenceEntry) comments() []string {
+	var res []string
+	if e.Description != "" {
+		res = append(res, e.Description)
+	}
+	details := e.Type
+	if e.Secret {
+		details += ", secret"
+	}
+	if e.Validation != "" {
+		details += ", validate: " + e.Validation
+	}
+	return append(res, details)
+}
+
+// referenceNode is a node of the tree of the keys written as YAML.
+type referenceNode struct {
+	name     string
+	entry    *ReferenceEntry
+	children []*referenceNode
+}
+
+func (n *referenceNode) add(path []string, entry *ReferenceEntry) {
+	if len(path) == 0 {
+		n.entry = entry
+		return
+	}
+	for _, child := range n.children {
+		if child.name == path[0] {
+			child.add(path[1:], entry)
+			return
+		}
+	}
+	child := &referenceNode{name: path[0]}
+	n.children = append(n.children, child)
+	child.add(path[1:], entry)
+}
+
+// writeYAML writes the children of the node. The first line of the first
+// child is prefixed by firstIndent instead of indent, so that the children of
+// list items can be written.
+func (n *referenceNode) writeYAML(b *strings.Builder, indent, firstIndent string) {
+	for i, child := range n.children {
+		lineIndent := indent
+		if i == 0 {
+			lineIndent = firstIndent
+		}
+		if child.entry != nil {
+			for _, comment := range child.entry.comments() {
+				fmt.Fprintf(b, "%s# %s\n", indent, comment)
+			}
+			fmt.Fprintf(b, "%s%s:%s\n", lineIndent, child.name, yamlValue(child.entry))
+			continue
+		}
+		fmt.Fprintf(b, "%s%s:\n", lineIndent, child.name)
+		if len(child.children) == 1 && child.children[0].name == ListItemPlaceholder {
+			child.children[0].writeYAML(b, indent+"    ", indent+"  - ")
+			continue
+		}
+		child.writeYAML(b, indent+"  ", indent+"  ")
+	}
+}
+
+// yamlValue returns the default value of the entry formatted for YAML, with
+// a leading space, or an empty string if there is no default value.
+func yamlValue(entry *ReferenceEntry) string {
+	value := entry.Example
+	if value == "" {
+		value = entry.Default
+	}
+	if value == "" {
+		return ""
+	}
+	if strings.HasPrefix(entry.Type, "[]") {
+		values := strings.Split(value, ",")
+		for i, value := range values {
+			values[i] = yamlScalar(entry.Type[2:], value)
+		}
+		return " [" + strings.Join(values, ", ") + "]"
+	}
+	return " " + yamlScalar(entry.Type, value)
+}
+
+// yamlScalar returns value formatted for YAML, strings being quoted if
+// needed.
+func yamlScalar(typeName, value string) string {
+	if typeName != "string" {
+		return value
+	}
+	out, err := yaml.Marshal(value)
+	if err != nil {
+		return value
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+func markdownCode(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "`" + markdownEscape(value) + "`"
+}
+
+func markdownEscape(value string) string {
+	return strings.ReplaceAll(value, "|", "\\|")
+}
@@ -0,0 +1,122 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+type ReferenceTestUpstream struct {
+	URL     string        `configkey:"url" validate:"required" desc:"URL of the upstream"`
+	Timeout time.Duration `configkey:"timeout,duration" default:"5s"`
+}
+
+type ReferenceTestConfig struct {
+	Address   string                           `configkey:"server.address" default:"0.0.0.0:8080" desc:"Address to listen on"`
+	Tags      []string                         `configkey:"server.tags" default:"a,b"`
+	Token     string                           `configkey:"server.token,secret" validate:"required" example:"${TOKEN}"`
+	Upstreams []ReferenceTestUpstream          `configkey:"server.upstreams"`
+	Backends  map[string]ReferenceTestUpstream `configkey:"server.backends"`
+	Ignored   string
+}
+
+func TestReference_WithComponentConfig_ReturnsEntries(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	entries, err := Reference(&ReferenceTestConfig{})
+
+	// Assert
+	assert.NoError(err)
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	assert.Equal([]string{
+		"server.address",
+		"server.tags",
+		"server.token",
+		"server.upstreams.[].url",
+		"server.upstreams.[].timeout",
+		"server.backends.<name>.url",
+		"server.backends.<name>.timeout",
+	}, keys)
+	assert.Equal(ReferenceEntry{
+		Key:         "server.address",
+		Type:        "string",
+		Default:     "0.0.0.0:8080",
+		Description: "Address to listen on",
+	}, entries[0])
+	assert.True(entries[2].Secret)
+	assert.Equal("${TOKEN}", entries[2].Example)
+	assert.Equal("required", entries[2].Validation)
+	assert.Equal("duration", entries[4].Type)
+}
+
+func TestReference_WithNonStruct_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	_, err := Reference("hoge")
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestWriteReferenceYAML_WithComponentConfig_WritesValidYAML(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	entries, _ := Reference(&ReferenceTestConfig{})
+	var b strings.Builder
+
+	// Act
+	err := WriteReferenceYAML(&b, entries)
+
+	// Assert
+	assert.NoError(err)
+	assert.Contains(b.String(), "  # Address to listen on\n  # string\n  address: 0.0.0.0:8080\n")
+	assert.Contains(b.String(), "  token: ${TOKEN}\n")
+	var actual map[string]interface{}
+	assert.NoError(yaml.Unmarshal([]byte(b.String()), &actual))
+	config, err := NewConfigurationFromReader("yaml", strings.NewReader(b.String()))
+	assert.NoError(err)
+	assert.Equal([]string{"a", "b"}, config.GetStringSlice("server.tags"))
+	assert.Equal("5s", config.GetString("server.upstreams.0.timeout"))
+	assert.Equal("5s", config.GetString("server.backends.<name>.timeout"))
+}
+
+func TestWriteReferenceEnv_WithAppName_WritesVariables(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	entries, _ := Reference(&ReferenceTestConfig{})
+	var b strings.Builder
+
+	// Act
+	err := WriteReferenceEnv(&b, "app", entries)
+
+	// Assert
+	assert.NoError(err)
+	assert.Contains(b.String(), "# Address to listen on\n# string\nAPP_SERVER_ADDRESS=0.0.0.0:8080\n")
+	assert.Contains(b.String(), "# string, secret, validate: required\nAPP_SERVER_TOKEN=\n")
+}
+
+func TestWriteReferenceMarkdown_WithAppName_WritesTable(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	entries, _ := Reference(&ReferenceTestConfig{})
+	var b strings.Builder
+
+	// Act
+	err := WriteReferenceMarkdown(&b, "app", entries)
+
+	// Assert
+	assert.NoError(err)
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Len(lines, len(entries)+2)
+	assert.Equal("| `server.address` | `APP_SERVER_ADDRESS` | string | `0.0.0.0:8080` |  | Address to listen on |", lines[2])
+}
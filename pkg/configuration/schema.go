@@ -0,0 +1,556 @@
+package conf
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/utils/iso8601"
+	"github.com/pkg/errors"
+)
+
+// goDurationPattern matches the durations in the go format (e.g. "1h30m"), or
+// given as a number of nanoseconds.
+const goDurationPattern = `^[-+]?((([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|ms|s|m|h))+|[0-9]+)$`
+
+// JSONSchemaVersion is the JSON Schema draft of the schemas returned by Schema.
+const JSONSchemaVersion = "http://json-schema.org/draft-07/schema#"
+
+// JSONSchema is a JSON Schema document, limited to the keywords used to
+// describe component configurations.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	MinProperties        *int                   `json:"minProperties,omitempty"`
+	MaxProperties        *int                   `json:"maxProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+}
+
+// Schema returns a JSON Schema describing the configuration files of the
+// given component configurations (structs or pointers to structs): the
+// configkey paths are translated into nested objects, the default tags into
+// defaults, the desc tags into descriptions and the common validate rules
+// (required, min, max, len, gt, gte, lt, lte, eq, oneof and dive) into
+// constraints. The other rules are ignored. The durations given as strings
+// must match the format of the field, Go (e.g. "1h30m") or ISO8601 (e.g.
+// "PT1H30M") if it has the iso8601 option.
+// Scalar values being converted when they are bound, string fields also
+// accept numbers and boolean values.
+func Schema(compConfs ...interface{}) (*JSONSchema, error) {
+	root := &JSONSchema{Schema: JSONSchemaVersion, Type: "object"}
+	for _, compConf := range compConfs {
+		t := reflect.TypeOf(compConf)
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			return nil, errors.Errorf("component configuration must be a struct, got %T", compConf)
+		}
+		addStructSchema(root, t)
+	}
+	return root, nil
+}
+
+// addStructSchema adds the fields of the struct type t to the object schema s.
+func addStructSchema(s *JSONSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tagValues, _ := parseConfigTag(field.Tag.Get(configTagName))
+		if tagValues[0] == "" {
+			continue
+		}
+		fieldSchema := typeSchema(field.Type, tagValues[1:])
+		fieldSchema.Description = field.Tag.Get(descTagName)
+		if defaultValue := field.Tag.Get(defaultTagName); defaultValue != "" {
+			fieldSchema.Default = schemaDefault(fieldSchema, defaultValue)
+		}
+		required := applyValidateRules(fieldSchema, field.Tag.Get(validateTagName))
+
+		parent := s
+		path := strings.Split(tagValues[0], ".")
+		for _, name := range path[:len(path)-1] {
+			if required {
+				parent.addRequired(name)
+			}
+			parent = parent.property(name)
+		}
+		name := path[len(path)-1]
+		if required {
+			parent.addRequired(name)
+		}
+		if existing, ok := parent.Properties[name]; ok && existing.Properties != nil && fieldSchema.Properties != nil {
+			mergeObjectSchemas(existing, fieldSchema)
+			continue
+		}
+		parent.setProperty(name, fieldSchema)
+	}
+}
+
+// typeSchema returns the schema of the values of type t.
+func typeSchema(t reflect.Type, options []string) *JSONSchema {
+	if _, ok := lookupDecoder(t); ok {
+		return &JSONSchema{}
+	}
+	switch {
+	case t.Kind() == reflect.Ptr:
+		return typeSchema(t.Elem(), options)
+	case t == durationType || (t.Kind() == reflect.Int64 && hasOption(options, durationTagValue)):
+		if hasOption(options, iso8601TagValue) {
+			return &JSONSchema{Type: "string", Pattern: iso8601.DurationRegex.String()}
+		}
+		return &JSONSchema{Type: []interface{}{"string", "integer"}, Pattern: goDurationPattern}
+	case t == timeType:
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case isTextType(t) || t == byteArrayType:
+		return &JSONSchema{Type: "string"}
+	case t.Kind() == reflect.Struct:
+		s := &JSONSchema{Type: "object"}
+		addStructSchema(s, t)
+		return s
+	case t.Kind() == reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), options)}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &JSONSchema{Type: "array", Items: typeSchema(t.Elem(), options)}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: []interface{}{"string", "number", "boolean"}}
+	}
+	return &JSONSchema{}
+}
+
+// applyValidateRules translates the rules of a validate tag into constraints
+// of s and returns whether the value is required. The rules following a dive
+// rule apply to the items of s.
+func applyValidateRules(s *JSONSchema, tag string) bool {
+	required := false
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		if rule == "dive" {
+			target := s.Items
+			if target == nil {
+				target = s.AdditionalProperties
+			}
+			if target != nil {
+				applyValidateRules(target, strings.Join(rules[i+1:], ","))
+			}
+			break
+		}
+		if rule == "required" {
+			required = true
+			continue
+		}
+		applyValidateRule(s, rule)
+	}
+	return required
+}
+
+// applyValidateRule translates a validate rule, with its alternatives, into
+// constraints of s.
+func applyValidateRule(s *JSONSchema, rule string) {
+	alternatives := strings.Split(rule, "|")
+	if len(alternatives) > 1 {
+		var enum []interface{}
+		for _, alternative := range alternatives {
+			name, param := splitRule(alternative)
+			if name != "eq" && name != "oneof" {
+				return
+			}
+			enum = append(enum, enumValues(s, name, param)...)
+		}
+		s.Enum = enum
+		return
+	}
+
+	name, param := splitRule(rule)
+	switch name {
+	case "eq", "oneof":
+		s.Enum = enumValues(s, name, param)
+	case "len":
+		applyBound(s, "min", param)
+		applyBound(s, "max", param)
+	case "min", "gte":
+		applyBound(s, "min", param)
+	case "max", "lte":
+		applyBound(s, "max", param)
+	case "gt", "lt":
+		if f, err := strconv.ParseFloat(param, 64); err == nil && s.hasType("number", "integer") {
+			if name == "gt" {
+				s.ExclusiveMinimum = &f
+			} else {
+				s.ExclusiveMaximum = &f
+			}
+		}
+	}
+}
+
+// applyBound sets the minimum ("min") or maximum ("max") of s, a value, a
+// length or a number of items depending on the type of s.
+func applyBound(s *JSONSchema, bound, param string) {
+	f, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	n := int(f)
+	switch {
+	case s.hasType("array"):
+		s.MinItems, s.MaxItems = selectBound(bound, n, s.MinItems, s.MaxItems)
+	case s.hasType("object"):
+		s.MinProperties, s.MaxProperties = selectBound(bound, n, s.MinProperties, s.MaxProperties)
+	case s.hasType("integer", "number") && !s.hasType("string"):
+		if bound == "min" {
+			s.Minimum = &f
+		} else {
+			s.Maximum = &f
+		}
+	case s.hasType("string"):
+		s.MinLength, s.MaxLength = selectBound(bound, n, s.MinLength, s.MaxLength)
+	}
+}
+
+func selectBound(bound string, n int, min, max *int) (*int, *int) {
+	if bound == "min" {
+		return &n, max
+	}
+	return min, &n
+}
+
+func splitRule(rule string) (string, string) {
+	if i := strings.Index(rule, "="); i >= 0 {
+		return rule[:i], rule[i+1:]
+	}
+	return rule, ""
+}
+
+// enumValues returns the values allowed by an eq or oneof rule.
+func enumValues(s *JSONSchema, name, param string) []interface{} {
+	values := []string{param}
+	if name == "oneof" {
+		values = strings.Fields(param)
+	}
+	res := make([]interface{}, len(values))
+	for i, value := range values {
+		res[i] = schemaScalar(s, value)
+	}
+	return res
+}
+
+// schemaDefault converts the value of a default tag to the type of s.
+func schemaDefault(s *JSONSchema, value string) interface{} {
+	if s.hasType("array") && s.Items != nil {
+		values := strings.Split(value, ",")
+		res := make([]interface{}, len(values))
+		for i, v := range values {
+			res[i] = schemaScalar(s.Items, v)
+		}
+		return res
+	}
+	return schemaScalar(s, value)
+}
+
+// schemaScalar converts value to the type of s, strings being kept as they
+// are.
+func schemaScalar(s *JSONSchema, value string) interface{} {
+	switch {
+	case s.hasType("string"):
+		return value
+	case s.hasType("integer"):
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	case s.hasType("number"):
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case s.hasType("boolean"):
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// types returns the types allowed by s.
+func (s *JSONSchema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		res := make([]string, len(t))
+		for i, v := range t {
+			res[i] = fmt.Sprint(v)
+		}
+		return res
+	}
+	return nil
+}
+
+// hasType returns whether s allows one of the given types.
+func (s *JSONSchema) hasType(types ...string) bool {
+	for _, t := range s.types() {
+		for _, expected := range types {
+			if t == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookupProperty returns the schema of the given property, the names of the
+// properties being case insensitive as the configuration keys.
+func (s *JSONSchema) lookupProperty(name string) (*JSONSchema, bool) {
+	for n, child := range s.Properties {
+		if strings.EqualFold(n, name) {
+			return child, true
+		}
+	}
+	return nil, false
+}
+
+func (s *JSONSchema) property(name string) *JSONSchema {
+	if child, ok := s.Properties[name]; ok {
+		return child
+	}
+	child := &JSONSchema{Type: "object"}
+	s.setProperty(name, child)
+	return child
+}
+
+func (s *JSONSchema) setProperty(name string, child *JSONSchema) {
+	if s.Properties == nil {
+		s.Properties = map[string]*JSONSchema{}
+	}
+	s.Properties[name] = child
+}
+
+func (s *JSONSchema) addRequired(name string) {
+	for _, r := range s.Required {
+		if r == name {
+			return
+		}
+	}
+	s.Required = append(s.Required, name)
+	sort.Strings(s.Required)
+}
+
+// mergeObjectSchemas adds the properties of src to dst, for the keys bound by
+// several fields.
+func mergeObjectSchemas(dst, src *JSONSchema) {
+	for name, child := range src.Properties {
+		if existing, ok := dst.Properties[name]; ok && existing.Properties != nil && child.Properties != nil {
+			mergeObjectSchemas(existing, child)
+			continue
+		}
+		dst.setProperty(name, child)
+	}
+	for _, name := range src.Required {
+		dst.addRequired(name)
+	}
+}
+
+// ValidateFile checks the configuration file at the given path against the
+// schema of the given component configurations (see Schema) and returns a
+// *ConfigError listing the violations. The file is checked alone: the default
+// values, the other sources and the environment variables are not taken into
+// account.
+func ValidateFile(path string, compConfs ...interface{}) error {
+	schema, err := Schema(compConfs...)
+	if err != nil {
+		return err
+	}
+	source := NewFileSource(path, false)
+	values, err := source.Load()
+	if err != nil {
+		return err
+	}
+	return ValidateSchema(schema, source.Name(), values)
+}
+
+// ValidateSchema checks the given values, as loaded by a Source with the given
+// name, against a schema returned by Schema and returns a *ConfigError
+// listing the violations.
+func ValidateSchema(schema *JSONSchema, sourceName string, values map[string]interface{}) error {
+	configErr := &ConfigError{}
+	validateSchemaValue(configErr, sourceName, "", normalizeValue(values), schema)
+	if len(configErr.Errors) > 0 {
+		return configErr
+	}
+	return nil
+}
+
+func validateSchemaValue(configErr *ConfigError, sourceName, key string, value interface{}, s *JSONSchema) {
+	report := func(format string, args ...interface{}) {
+		configErr.Errors = append(configErr.Errors, FieldError{
+			Key:    key,
+			Source: sourceName,
+			Reason: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if str, ok := value.(string); ok && (IsEncryptedValue(str) || strings.Contains(str, "${")) {
+		// encrypted values cannot be checked without the encryption key, nor
+		// interpolated values before they are resolved.
+		return
+	}
+	valueType := jsonType(value)
+	if types := s.types(); len(types) > 0 && !s.hasType(valueType) && !(valueType == "integer" && s.hasType("number")) {
+		report("value of type %s does not match the expected type %s", valueType, strings.Join(types, " or "))
+		return
+	}
+	if len(s.Enum) > 0 && !enumContains(s.Enum, value) {
+		report("value is not one of %v", s.Enum)
+	}
+
+	switch v := value.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			report("length must be at least %d", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			report("length must be at most %d", *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err != nil || !re.MatchString(v) {
+				report("value does not match the pattern %s", s.Pattern)
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			report("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			report("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				validateSchemaValue(configErr, sourceName, joinKey(key, strconv.Itoa(i)), item, s.Items)
+			}
+		}
+	case map[string]interface{}:
+		if s.MinProperties != nil && len(v) < *s.MinProperties {
+			report("must have at least %d entries", *s.MinProperties)
+		}
+		if s.MaxProperties != nil && len(v) > *s.MaxProperties {
+			report("must have at most %d entries", *s.MaxProperties)
+		}
+		for _, name := range s.Required {
+			if _, ok := v[strings.ToLower(name)]; !ok {
+				configErr.Errors = append(configErr.Errors, FieldError{
+					Key:    joinKey(key, name),
+					Reason: "value is required",
+				})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if child, ok := s.lookupProperty(name); ok {
+				validateSchemaValue(configErr, sourceName, joinKey(key, name), v[name], child)
+			} else if s.AdditionalProperties != nil {
+				validateSchemaValue(configErr, sourceName, joinKey(key, name), v[name], s.AdditionalProperties)
+			}
+		}
+	default:
+		if f, ok := toFloat(value); ok {
+			if s.Minimum != nil && f < *s.Minimum {
+				report("value must be at least %v", *s.Minimum)
+			}
+			if s.Maximum != nil && f > *s.Maximum {
+				report("value must be at most %v", *s.Maximum)
+			}
+			if s.ExclusiveMinimum != nil && f <= *s.ExclusiveMinimum {
+				report("value must be greater than %v", *s.ExclusiveMinimum)
+			}
+			if s.ExclusiveMaximum != nil && f >= *s.ExclusiveMaximum {
+				report("value must be less than %v", *s.ExclusiveMaximum)
+			}
+		}
+	}
+}
+
+// jsonType returns the JSON type of a value read from a configuration source.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case float32, float64:
+		if f, _ := toFloat(v); f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	if _, ok := toFloat(value); ok {
+		return "integer"
+	}
+	return "string"
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func enumContains(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
@@ -0,0 +1,183 @@
+package conf
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type SchemaTestItem struct {
+	Name string `configkey:"name" validate:"required"`
+}
+
+type SchemaTestConfig struct {
+	Port    int                       `configkey:"server.port" validate:"min=1,max=65535" default:"8080" desc:"Port to listen on"`
+	Format  string                    `configkey:"server.format" validate:"eq=json|eq=text"`
+	Level   string                    `configkey:"server.level" validate:"required,oneof=debug info"`
+	Tags    []string                  `configkey:"server.tags" validate:"max=2,dive,min=2" default:"ab,cd"`
+	Items   []SchemaTestItem          `configkey:"server.items"`
+	Entries map[string]SchemaTestItem `configkey:"server.entries"`
+}
+
+func TestSchema_WithComponentConfig_ReturnsSchema(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	schema, err := Schema(&SchemaTestConfig{})
+
+	// Assert
+	assert.NoError(err)
+	actual, _ := json.Marshal(schema)
+	assert.JSONEq(`{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type": "object",
+		"required": ["server"],
+		"properties": {
+			"server": {
+				"type": "object",
+				"required": ["level"],
+				"properties": {
+					"port": {"type": "integer", "description": "Port to listen on", "default": 8080, "minimum": 1, "maximum": 65535},
+					"format": {"type": ["string", "number", "boolean"], "enum": ["json", "text"]},
+					"level": {"type": ["string", "number", "boolean"], "enum": ["debug", "info"]},
+					"tags": {
+						"type": "array",
+						"default": ["ab", "cd"],
+						"maxItems": 2,
+						"items": {"type": ["string", "number", "boolean"], "minLength": 2}
+					},
+					"items": {
+						"type": "array",
+						"items": {
+							"type": "object",
+							"required": ["name"],
+							"properties": {"name": {"type": ["string", "number", "boolean"]}}
+						}
+					},
+					"entries": {
+						"type": "object",
+						"additionalProperties": {
+							"type": "object",
+							"required": ["name"],
+							"properties": {"name": {"type": ["string", "number", "boolean"]}}
+						}
+					}
+				}
+			}
+		}
+	}`, string(actual))
+}
+
+func TestValidateFile_WithValidFile_ReturnsNil(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, path, `
+server:
+  port: 443
+  format: json
+  level: info
+  tags: [ab]
+  items:
+    - name: first
+  entries:
+    main:
+      name: main
+  other: ignored
+`)
+
+	// Act
+	err := ValidateFile(path, &SchemaTestConfig{})
+
+	// Assert
+	assert.NoError(err)
+}
+
+func TestValidateFile_WithInvalidFile_ReturnsAllErrors(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, path, `
+server:
+  port: 70000
+  format: xml
+  tags: [a, bc, de]
+  items:
+    - other: 1
+  entries:
+    main:
+      other: 1
+`)
+
+	// Act
+	err := ValidateFile(path, &SchemaTestConfig{})
+
+	// Assert
+	var configErr *ConfigError
+	if assert.True(errors.As(err, &configErr)) {
+		keys := make([]string, len(configErr.Errors))
+		for i, fieldError := range configErr.Errors {
+			keys[i] = fieldError.Key
+		}
+		assert.ElementsMatch([]string{
+			"server.level",
+			"server.port",
+			"server.format",
+			"server.tags",
+			"server.tags.0",
+			"server.items.0.name",
+			"server.entries.main.name",
+		}, keys)
+		assert.Contains(err.Error(), "key [server.port] (source: file:"+path+"): value must be at most 65535")
+	}
+}
+
+type SchemaTestDurations struct {
+	Interval time.Duration `configkey:"server.interval,duration"`
+	Timeout  time.Duration `configkey:"server.timeout,duration,iso8601"`
+}
+
+func TestValidateFile_WithDurations_ChecksFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{"valid", "server:\n  interval: 1h30m\n  timeout: PT1H30M\n", nil},
+		{"nanoseconds", "server:\n  interval: 100\n", nil},
+		{"interpolated", "server:\n  interval: ${INTERVAL:-1h}\n", nil},
+		{"iso8601 as number", "server:\n  timeout: 100\n", []string{"server.timeout"}},
+		{"iso8601 as go", "server:\n  interval: PT24H\n", []string{"server.interval"}},
+		{"go as iso8601", "server:\n  timeout: 24h\n", []string{"server.timeout"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			assert := assert.New(t)
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			writeConfigFile(t, path, tt.content)
+
+			// Act
+			err := ValidateFile(path, &SchemaTestDurations{})
+
+			// Assert
+			if tt.expected == nil {
+				assert.NoError(err)
+				return
+			}
+			var configErr *ConfigError
+			if assert.True(errors.As(err, &configErr)) {
+				keys := make([]string, len(configErr.Errors))
+				for i, fieldError := range configErr.Errors {
+					keys[i] = fieldError.Key
+				}
+				assert.Equal(tt.expected, keys)
+			}
+		})
+	}
+}
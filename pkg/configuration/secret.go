@@ -0,0 +1,133 @@
+package conf
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SecretResolver resolves secret references found in configuration values.
+// A reference is an interpolation of the form ${secret:<scheme>:<reference>},
+// e.g. "${secret:file:///run/secrets/db_password}" or
+// "${secret:env:DB_PASSWORD}", other values being left unchanged.
+// Resolved values are only returned to the component configurations, they are
+// never logged nor dumped.
+type SecretResolver interface {
+	// Scheme returns the scheme of the references handled by the resolver.
+	Scheme() string
+	// Resolve returns the secret value for the given reference (the scheme
+	// and colon being removed).
+	Resolve(reference string) (string, error)
+}
+
+// FileSecretResolver resolves "file:" references by reading the referenced
+// file, e.g. "${secret:file:///run/secrets/db_password}". Trailing new lines are
+// removed.
+type FileSecretResolver struct{}
+
+// Scheme returns "file".
+func (FileSecretResolver) Scheme() string {
+	return "file"
+}
+
+// Resolve reads the referenced file.
+func (FileSecretResolver) Resolve(reference string) (string, error) {
+	path := strings.TrimPrefix(reference, "//")
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read secret file [%s]", path)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// EnvSecretResolver resolves "env:" references with the value of the
+// referenced environment variable, e.g. "${secret:env:DB_PASSWORD}".
+type EnvSecretResolver struct{}
+
+// Scheme returns "env".
+func (EnvSecretResolver) Scheme() string {
+	return "env"
+}
+
+// Resolve returns the value of the referenced environment variable.
+func (EnvSecretResolver) Resolve(reference string) (string, error) {
+	value, ok := os.LookupEnv(reference)
+	if !ok {
+		return "", errors.Errorf("environment variable [%s] is not set", reference)
+	}
+	return value, nil
+}
+
+// Base64SecretResolver resolves "base64:" references by decoding the base64
+// (standard encoding) value following the scheme.
+type Base64SecretResolver struct{}
+
+// Scheme returns "base64".
+func (Base64SecretResolver) Scheme() string {
+	return "base64"
+}
+
+// Resolve decodes the reference.
+func (Base64SecretResolver) Resolve(reference string) (string, error) {
+	value, err := base64.StdEncoding.DecodeString(reference)
+	if err != nil {
+		return "", errors.New("invalid base64 secret value")
+	}
+	return string(value), nil
+}
+
+// defaultSecretResolvers returns the resolvers registered on new
+// configurations.
+func defaultSecretResolvers() map[string]SecretResolver {
+	res := map[string]SecretResolver{}
+	for _, r := range []SecretResolver{
+		FileSecretResolver{}, EnvSecretResolver{}, Base64SecretResolver{},
+	} {
+		res[r.Scheme()] = r
+	}
+	return res
+}
+
+// RegisterSecretResolver registers a resolver for the references with the
+// scheme of the resolver, replacing any resolver previously registered for
+// this scheme. The "file", "env" and "base64" schemes are registered by
+// default.
+func (c *Configuration) RegisterSecretResolver(resolver SecretResolver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resolvers := make(map[string]SecretResolver, len(c.resolvers)+1)
+	for scheme, r := range c.resolvers {
+		resolvers[scheme] = r
+	}
+	resolvers[resolver.Scheme()] = resolver
+	c.resolvers = resolvers
+}
+
+// getResolvers returns the registered secret resolvers.
+func (c *Configuration) getResolvers() map[string]SecretResolver {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.resolvers
+}
+
+// resolveSecret returns the secret of a ${secret:<scheme>:<reference>}
+// interpolation, given without the secret prefix. The secret value is never
+// part of the returned error.
+func resolveSecret(resolvers map[string]SecretResolver, key, value string) (string, error) {
+	i := strings.Index(value, ":")
+	if i <= 0 {
+		return "", errors.Errorf("invalid secret reference in key [%s], expecting <scheme>:<reference>", key)
+	}
+	resolver, ok := resolvers[value[:i]]
+	if !ok {
+		return "", errors.Errorf("unknown secret scheme [%s] in key [%s]", value[:i], key)
+	}
+	secret, err := resolver.Resolve(value[i+1:])
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve %s secret of key [%s]", resolver.Scheme(), key)
+	}
+	return secret, nil
+}
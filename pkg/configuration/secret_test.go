@@ -0,0 +1,162 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubVaultResolver struct {
+	secrets map[string]string
+}
+
+func (r stubVaultResolver) Scheme() string {
+	return "vault"
+}
+
+func (r stubVaultResolver) Resolve(reference string) (string, error) {
+	secret, ok := r.secrets[reference]
+	if !ok {
+		return "", errors.Errorf("secret [%s] not found", reference)
+	}
+	return secret, nil
+}
+
+type SecretTestConfig struct {
+	Password string   `configkey:"secret.password" validate:"required"`
+	Tokens   []string `configkey:"secret.tokens"`
+}
+
+func createSecretConfiguration(t *testing.T, content string) *Configuration {
+	config, err := NewConfigurationFromReader("yaml", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("reading configuration failed: %s", err)
+	}
+	return config
+}
+
+func TestConfigurationGetString_WithFileReference_ReturnsFileContent(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "db_password")
+	writeConfigFile(t, path, "s3cr3t\n")
+	config := createSecretConfiguration(t, "secret:\n  password: ${secret:file://"+path+"}\n")
+
+	// Act
+	actual := config.GetString("secret.password")
+
+	// Assert
+	assert.Equal("s3cr3t", actual)
+}
+
+func TestConfigurationGetString_WithEnvReference_ReturnsEnvValue(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	os.Setenv("SECRET_TEST_PASSWORD", "s3cr3t")
+	defer os.Unsetenv("SECRET_TEST_PASSWORD")
+	config := createSecretConfiguration(t, "secret:\n  password: ${secret:env:SECRET_TEST_PASSWORD}\n")
+
+	// Act
+	actual := config.GetString("secret.password")
+
+	// Assert
+	assert.Equal("s3cr3t", actual)
+}
+
+func TestConfigurationGetStringSlice_WithBase64References_ReturnsDecodedValues(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createSecretConfiguration(t, "secret:\n  tokens:\n    - ${secret:base64:czNjcjN0}\n    - plain\n")
+
+	// Act
+	actual := config.GetStringSlice("secret.tokens")
+
+	// Assert
+	assert.Equal([]string{"s3cr3t", "plain"}, actual)
+}
+
+func TestConfigurationInitializeComponentConfig_WithRegisteredResolver_ResolvesSecret(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createSecretConfiguration(t, "secret:\n  password: ${secret:vault:db/password}\n")
+	config.RegisterSecretResolver(stubVaultResolver{secrets: map[string]string{"db/password": "s3cr3t"}})
+	secretConfig := &SecretTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(secretConfig)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("s3cr3t", secretConfig.Password)
+}
+
+func TestConfigurationInitializeComponentConfig_WithUnresolvableReference_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createSecretConfiguration(t, "secret:\n  password: ${secret:env:SECRET_TEST_MISSING}\n")
+	secretConfig := &SecretTestConfig{}
+
+	// Act
+	err := config.InitializeComponentConfig(secretConfig)
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "secret.password")
+}
+
+func TestConfigurationGetString_WithUnknownScheme_ReturnsRawValue(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createSecretConfiguration(t, "secret:\n  password: https://example.com\n")
+
+	// Act
+	actual := config.GetString("secret.password")
+
+	// Assert
+	assert.Equal("https://example.com", actual)
+}
+
+func TestConfigurationGetString_WithUnprefixedReference_ReturnsRawValue(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createSecretConfiguration(t, "secret:\n  password: file:///run/secrets/missing\n  token: env:SECRET_TEST_MISSING\n")
+
+	// Act
+	password := config.GetString("secret.password")
+	token := config.GetString("secret.token")
+
+	// Assert
+	assert.Equal("file:///run/secrets/missing", password)
+	assert.Equal("env:SECRET_TEST_MISSING", token)
+}
+
+func TestConfigurationGetString_WithUnresolvableReference_Panics(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createSecretConfiguration(t, "secret:\n  password: ${secret:env:SECRET_TEST_MISSING}\n")
+
+	// Act
+	act := func() { config.GetString("secret.password") }
+	_, err := config.GetStringE("secret.password")
+
+	// Assert
+	assert.PanicsWithError("failed to read key [secret.password]: failed to resolve env secret of key [secret.password]: environment variable [SECRET_TEST_MISSING] is not set", act)
+	assert.Error(err)
+}
+
+func TestConfigurationGetStringE_WithUnknownSecretScheme_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createSecretConfiguration(t, "secret:\n  password: ${secret:vault:db/password}\n")
+
+	// Act
+	_, err := config.GetStringE("secret.password")
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "unknown secret scheme [vault]")
+}
@@ -0,0 +1,713 @@
+package conf
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Source is a layer of configuration values that can be stacked on a
+// Configuration with AddSource.
+//
+// The value of a key is taken from the source with the highest precedence
+// defining it. From the lowest to the highest precedence:
+//  1. default values given with the `default` struct tag
+//  2. file, reader and map sources (and any custom Source), in the order they
+//     were added: a source overrides the values of the sources added before it
+//  3. environment variables (EnvSource)
+//  4. command line flags which were explicitly set (FlagSource)
+type Source interface {
+	// Name identifies the source, it is returned by SourceOf.
+	Name() string
+	// Load returns the tree of values provided by the source. Nested keys are
+	// represented with nested maps.
+	Load() (map[string]interface{}, error)
+}
+
+const defaultSourceName = "default"
+
+// FileSource reads values from a configuration file. The format is deduced
+// from the file extension.
+//
+// A file can include other files with the include key, a path or a list of
+// paths which can be glob patterns, relative to the directory of the file:
+//	include:
+//	  - common.yml
+//	  - conf.d/*.yml
+// The included files are merged in the order they are listed, the files
+// matching a pattern in lexical order, and the values of the including file
+// override the included values. Load fails on a missing file or an include
+// cycle, whereas a pattern matching no file is ignored.
+type FileSource struct {
+	// Path of the file. If empty, the file named BaseName with any supported
+	// extension is searched in SearchPaths (in order).
+	Path        string
+	BaseName    string
+	SearchPaths []string
+	// Optional specifies whether a missing file is ignored.
+	Optional bool
+
+	mu sync.Mutex
+	// includes holds the absolute paths and patterns of the files included
+	// by the file when it was last loaded.
+	includes []string
+}
+
+// NewFileSource returns a source reading the file at the given path.
+func NewFileSource(path string, optional bool) *FileSource {
+	return &FileSource{Path: path, Optional: optional}
+}
+
+// NewSearchFileSource returns a source reading the first file named baseName
+// (suffix omitted) found in the given paths.
+func NewSearchFileSource(baseName string, searchPaths []string, optional bool) *FileSource {
+	return &FileSource{BaseName: baseName, SearchPaths: searchPaths, Optional: optional}
+}
+
+// Name returns the name of the source.
+func (s *FileSource) Name() string {
+	if path, ok := s.resolve(); ok {
+		return "file:" + path
+	}
+	if s.Path != "" {
+		return "file:" + s.Path
+	}
+	return "file:" + s.BaseName
+}
+
+// Load reads the file and the files it includes.
+func (s *FileSource) Load() (map[string]interface{}, error) {
+	path, ok := s.resolve()
+	if !ok {
+		if s.Optional {
+			return map[string]interface{}{}, nil
+		}
+		if s.Path != "" {
+			return nil, errors.Errorf("failed to read config file: [%s] not found", s.Path)
+		}
+		return nil, errors.Errorf("failed to read config file: [%s] (suffix ommitted) not found in %v", s.BaseName, s.SearchPaths)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read config file: [%s]", path)
+	}
+	values, err := readValues(strings.TrimPrefix(filepath.Ext(path), "."), content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse config file: [%s]", path)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read config file: [%s]", path)
+	}
+	values, includes, err := resolveIncludes(values, filepath.Dir(absPath), []string{absPath})
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.includes = includes
+	s.mu.Unlock()
+	return values, nil
+}
+
+// resolve returns the path of the file to read and whether it exists.
+func (s *FileSource) resolve() (string, bool) {
+	if s.Path != "" {
+		return s.Path, fileExists(s.Path)
+	}
+	for _, dir := range s.SearchPaths {
+		for _, ext := range viper.SupportedExts {
+			path := filepath.Join(dir, s.BaseName+"."+ext)
+			if fileExists(path) {
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
+
+// dirs returns the directories where the file and the files it includes can
+// be found.
+func (s *FileSource) dirs() []string {
+	var res []string
+	if s.Path != "" {
+		res = append(res, filepath.Dir(filepath.Clean(s.Path)))
+	} else {
+		for _, dir := range s.SearchPaths {
+			res = append(res, filepath.Clean(dir))
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, include := range s.includes {
+		if dir := filepath.Dir(include); !hasGlobMeta(dir) && dirExists(dir) {
+			res = append(res, dir)
+		}
+	}
+	return res
+}
+
+// matches returns whether the given path is a candidate for the source file
+// or one of the files it includes.
+func (s *FileSource) matches(path string) bool {
+	path = filepath.Clean(path)
+	if s.matchesInclude(path) {
+		return true
+	}
+	if s.Path != "" {
+		return path == filepath.Clean(s.Path)
+	}
+	dir, name := filepath.Split(path)
+	dir = filepath.Clean(dir)
+	for _, d := range s.SearchPaths {
+		if filepath.Clean(d) != dir {
+			continue
+		}
+		for _, ext := range viper.SupportedExts {
+			if name == s.BaseName+"."+ext {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *FileSource) matchesInclude(path string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, include := range s.includes {
+		if ok, _ := filepath.Match(include, absPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ReaderSource provides values read from a stream.
+type ReaderSource struct {
+	name    string
+	format  string
+	content []byte
+}
+
+// NewReaderSource returns a source holding the content read from the given
+// stream using the provided format (see NewConfigurationFromReader).
+// The stream is read immediately so that the source can be loaded again on
+// reload.
+func NewReaderSource(name, format string, in io.Reader) (*ReaderSource, error) {
+	content, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read source %s", name)
+	}
+	return &ReaderSource{name: name, format: format, content: content}, nil
+}
+
+// Name returns the name of the source.
+func (s *ReaderSource) Name() string {
+	return s.name
+}
+
+// Load parses the content of the source. The paths of the included files
+// (see FileSource) are relative to the working directory.
+func (s *ReaderSource) Load() (map[string]interface{}, error) {
+	values, err := readValues(s.format, s.content)
+	if err != nil {
+		return nil, err
+	}
+	values, _, err = resolveIncludes(values, "", nil)
+	return values, err
+}
+
+// MapSource provides values from a map. Keys can either be nested maps or
+// dotted paths (e.g. "database.host").
+type MapSource struct {
+	name   string
+	values map[string]interface{}
+}
+
+// NewMapSource returns a source providing the given values.
+func NewMapSource(name string, values map[string]interface{}) *MapSource {
+	return &MapSource{name: name, values: values}
+}
+
+// Name returns the name of the source.
+func (s *MapSource) Name() string {
+	return s.name
+}
+
+// Load returns a copy of the values of the source.
+func (s *MapSource) Load() (map[string]interface{}, error) {
+	res := map[string]interface{}{}
+	for key, value := range s.values {
+		setPath(res, strings.Split(strings.ToLower(key), "."), copyValue(value))
+	}
+	return res, nil
+}
+
+// EnvSource enables the override of any key by an environment variable named
+// after the key: the upper-cased key, prefixed by Prefix and an underscore,
+// where dots are replaced by underscores (e.g. CORE_DATABASE_HOST for the key
+// database.host with the prefix core).
+// The keys of sub configurations, of nested structs, of map entries and of
+// list items are named after their full path, e.g. CORE_UPSTREAMS_MAIN_URL for
+// the key url of the entry main of the map upstreams, or CORE_ITEMS_0_NAME for
+// the key name of the first item of the list items.
+// Map entries can be added with environment variables only: the entry name is
+// the part of the variable name between the map key and the key of a field of
+// the entry (or the end of the name for maps of values).
+// Environment variables are looked up each time a value is read, Load returns
+// no values.
+type EnvSource struct {
+	Prefix string
+}
+
+// NewEnvSource returns a source reading environment variables with the given
+// prefix.
+func NewEnvSource(prefix string) *EnvSource {
+	return &EnvSource{Prefix: prefix}
+}
+
+// Name returns the name of the source.
+func (s *EnvSource) Name() string {
+	return "env"
+}
+
+// Load returns no values, see EnvSource.
+func (s *EnvSource) Load() (map[string]interface{}, error) {
+	return nil, nil
+}
+
+// VariableName returns the name of the environment variable overriding the
+// given key.
+func (s *EnvSource) VariableName(key string) string {
+	name := strings.ToUpper(key)
+	if s.Prefix != "" {
+		name = strings.ToUpper(s.Prefix) + "_" + name
+	}
+	return envKeyReplacer.Replace(name)
+}
+
+var envKeyReplacer = strings.NewReplacer(".", "_")
+
+// envVariables returns the values of the environment variables overriding the
+// children of the given key, by the part of their name following the name of
+// the variable of the key, e.g. "K1_VALUE" for "APP_STRING_MAP_K1_VALUE" and
+// the key "string_map".
+func (c *Configuration) envVariables(key string) map[string]string {
+	res := map[string]string{}
+	for _, s := range c.getLoaded() {
+		es, ok := s.source.(*EnvSource)
+		if !ok {
+			continue
+		}
+		prefix := es.VariableName(c.prefix+key) + "_"
+		for _, variable := range os.Environ() {
+			i := strings.Index(variable, "=")
+			if i < 0 || i == len(variable)-1 || !strings.HasPrefix(variable[:i], prefix) {
+				continue
+			}
+			if name := variable[len(prefix):i]; name != "" {
+				res[name] = variable[i+1:]
+			}
+		}
+	}
+	return res
+}
+
+// envEntries returns the names of the entries of the map of the given key
+// which are set with environment variables. The variables of the entries of a
+// map of structs end with the variable name of a field of the struct, e.g.
+// "APP_UPSTREAMS_MAIN_URL" for the "url" field of the entry "main" of the
+// "upstreams" map. The other variables give the value of an entry, e.g.
+// "APP_LIMITS_READ" for the entry "read" of the "limits" map.
+func (c *Configuration) envEntries(key string, vType reflect.Type) []string {
+	variables := c.envVariables(key)
+	if len(variables) == 0 {
+		return nil
+	}
+	var suffixes []string
+	if isStructType(vType) {
+		for _, entry := range appendReferenceEntries(nil, "", indirectType(vType)) {
+			suffixes = append(suffixes, "_"+envKeyReplacer.Replace(strings.ToUpper(entry.Key)))
+		}
+	}
+	names := map[string]bool{}
+	for name := range variables {
+		if len(suffixes) == 0 {
+			names[strings.ToLower(name)] = true
+			continue
+		}
+		longest := ""
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(name, suffix) && len(name) > len(suffix) && len(suffix) > len(longest) {
+				longest = suffix
+			}
+		}
+		if longest != "" {
+			names[strings.ToLower(strings.TrimSuffix(name, longest))] = true
+		}
+	}
+	res := make([]string, 0, len(names))
+	for name := range names {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// FlagSource overrides keys with the command line flags of the same name
+// (e.g. -database.host) which were explicitly set. The flag set must be parsed
+// before the configuration is initialized.
+// Flags are looked up each time a value is read, Load returns no values.
+type FlagSource struct {
+	FlagSet *flag.FlagSet
+}
+
+// NewFlagSource returns a source reading the given flag set.
+func NewFlagSource(fs *flag.FlagSet) *FlagSource {
+	return &FlagSource{FlagSet: fs}
+}
+
+// Name returns the name of the source.
+func (s *FlagSource) Name() string {
+	return "flag"
+}
+
+// Load returns no values, see FlagSource.
+func (s *FlagSource) Load() (map[string]interface{}, error) {
+	return nil, nil
+}
+
+// isSet returns whether the flag named after the given key was set.
+func (s *FlagSource) isSet(key string) bool {
+	set := false
+	s.FlagSet.Visit(func(f *flag.Flag) {
+		if strings.ToLower(f.Name) == key {
+			set = true
+		}
+	})
+	return set
+}
+
+// VisitAll implements viper.FlagValueSet. Only the flags which were set are
+// visited so that flag default values do not shadow the other sources.
+func (s *FlagSource) VisitAll(fn func(viper.FlagValue)) {
+	s.FlagSet.Visit(func(f *flag.Flag) {
+		fn(flagValue{flag: f})
+	})
+}
+
+// flagValue implements viper.FlagValue for a standard library flag which was
+// set.
+type flagValue struct {
+	flag *flag.Flag
+}
+
+func (f flagValue) HasChanged() bool {
+	return true
+}
+
+func (f flagValue) Name() string {
+	return f.flag.Name
+}
+
+func (f flagValue) ValueString() string {
+	return f.flag.Value.String()
+}
+
+func (f flagValue) ValueType() string {
+	if b, ok := f.flag.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return "bool"
+	}
+	return "string"
+}
+
+// loadedSource is a source with the flattened keys of the values it provided
+// at the last load.
+type loadedSource struct {
+	source Source
+	keys   map[string]bool
+}
+
+// AddSource adds sources to the configuration, see Source for the precedence
+// rules. Sources must be added before the configuration is initialized, or
+// they are only taken into account at the next reload.
+//
+// If no file, reader or map source is added, Initialize uses the
+// <EnvironmentName>.<ext> file searched in the configuration search paths,
+// layered between default.<ext> and <EnvironmentName>.local.<ext> if
+// SetLayeredFiles is enabled (see SetLayeredFiles).
+//
+// If no EnvSource is added, Initialize uses environment variables prefixed by
+// the application name.
+func (c *Configuration) AddSource(sources ...Source) {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	c.sources = append(c.sources, sources...)
+}
+
+// SourceOf returns the name of the source supplying the value of the given
+// key: the name of a Source, "default" if the value is a default value, or an
+// empty string if the key is not set.
+func (c *Configuration) SourceOf(key string) string {
+	c.ensureInitialized()
+	return c.sourceOfPath(c.prefix + key)
+}
+
+// sourceOfPath returns the name of the source supplying the value of the key
+// with the given full path.
+func (c *Configuration) sourceOfPath(key string) string {
+	key = strings.ToLower(key)
+	sources := c.getLoaded()
+
+	for _, s := range sources {
+		if fs, ok := s.source.(*FlagSource); ok && fs.isSet(key) {
+			return fs.Name()
+		}
+	}
+	for _, s := range sources {
+		if es, ok := s.source.(*EnvSource); ok {
+			// the variable of a list also supplies its items.
+			for k := key; k != ""; k = parentKey(k) {
+				if value, ok := os.LookupEnv(es.VariableName(k)); ok && value != "" {
+					return es.Name()
+				}
+			}
+		}
+	}
+	for i := len(sources) - 1; i >= 0; i-- {
+		if sources[i].hasKey(key) {
+			return sources[i].source.Name()
+		}
+	}
+	if c.defaults.has(key) {
+		return defaultSourceName
+	}
+	return ""
+}
+
+// parentKey returns the key of the parent of the given key, empty for a top
+// level key.
+func parentKey(key string) string {
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// getLoaded returns the sources the configuration values were loaded from.
+func (c *Configuration) getLoaded() []loadedSource {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loaded
+}
+
+// hasKey returns whether the source provided a value for the key, for one of
+// its children or for one of its parents holding a value, e.g. the comma
+// separated string holding a list item.
+func (s loadedSource) hasKey(key string) bool {
+	if s.keys[key] {
+		return true
+	}
+	for k := range s.keys {
+		if strings.HasPrefix(k, key+".") || strings.HasPrefix(key, k+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetLayeredFiles enables or disables the layered files. When enabled and no
+// file, reader or map source is added, Initialize also loads the optional
+// files searched in the configuration search paths:
+//   - default.<ext>, overridden by the <EnvironmentName>.<ext> file,
+//   - <EnvironmentName>.local.<ext>, overriding the <EnvironmentName>.<ext>
+//     file, e.g. for the settings of a developer which are not committed.
+// The layered files are disabled by default.
+func (c *Configuration) SetLayeredFiles(layered bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.layeredFiles = layered
+}
+
+func (c *Configuration) isLayeredFiles() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.layeredFiles
+}
+
+// standardSources returns the sources used when none were added.
+func (c *Configuration) standardSources() ([]Source, error) {
+	var sources []Source
+	if !hasValueSource(c.sources) {
+		if strings.TrimSpace(c.EnvironmentName) == "" {
+			return nil, errors.Errorf("environment name is missing")
+		}
+		envSource := NewSearchFileSource(c.EnvironmentName, c.paths, false)
+		if c.isLayeredFiles() {
+			sources = append(sources,
+				NewSearchFileSource("default", c.paths, true),
+				envSource,
+				NewSearchFileSource(c.EnvironmentName+".local", c.paths, true))
+		} else {
+			sources = append(sources, envSource)
+		}
+	}
+	sources = append(sources, c.sources...)
+	if !hasEnvSource(c.sources) {
+		sources = append(sources, NewEnvSource(c.AppName))
+	}
+	return sources, nil
+}
+
+// load reads all the sources into a new viper instance.
+func (c *Configuration) load() (*viper.Viper, []loadedSource, error) {
+	v := viper.New()
+	loaded := make([]loadedSource, 0, len(c.sources))
+	for _, source := range c.sources {
+		switch s := source.(type) {
+		case *EnvSource:
+			v.SetEnvPrefix(s.Prefix)
+			v.AutomaticEnv()
+			v.SetEnvKeyReplacer(envKeyReplacer)
+		case *FlagSource:
+			if err := v.BindFlagValues(s); err != nil {
+				return nil, nil, errors.Wrap(err, "failed to bind flags")
+			}
+		default:
+			values, err := source.Load()
+			if err != nil {
+				return nil, nil, err
+			}
+			keys := map[string]bool{}
+			flattenKeys(values, "", keys)
+			if err := v.MergeConfigMap(values); err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to merge source %s", source.Name())
+			}
+			loaded = append(loaded, loadedSource{source: source, keys: keys})
+			continue
+		}
+		loaded = append(loaded, loadedSource{source: source})
+	}
+	c.defaults.applyTo(v)
+	return v, loaded, nil
+}
+
+func hasValueSource(sources []Source) bool {
+	for _, s := range sources {
+		switch s.(type) {
+		case *EnvSource, *FlagSource:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+func hasEnvSource(sources []Source) bool {
+	for _, s := range sources {
+		if _, ok := s.(*EnvSource); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// readValues parses the given content using the provided format.
+func readValues(format string, content []byte) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
+
+// flattenKeys adds the dotted paths of the leaves of values to keys, the items
+// of the lists being given by their index (e.g. "servers.0.host").
+func flattenKeys(values map[string]interface{}, prefix string, keys map[string]bool) {
+	for k, value := range values {
+		flattenKey(strings.ToLower(prefix+k), value, keys)
+	}
+}
+
+func flattenKey(key string, value interface{}, keys map[string]bool) {
+	if m, ok := toStringMap(value); ok && len(m) > 0 {
+		flattenKeys(m, key+".", keys)
+		return
+	}
+	keys[key] = true
+	if items, ok := value.([]interface{}); ok {
+		for i, item := range items {
+			flattenKey(key+"."+strconv.Itoa(i), item, keys)
+		}
+	}
+}
+
+// toStringMap returns the given value as a map with string keys if it is a
+// map.
+func toStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			res[strings.ToLower(fmt.Sprint(k))] = v
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// setPath sets value at the given path in m, creating the intermediate maps.
+func setPath(m map[string]interface{}, path []string, value interface{}) {
+	for _, p := range path[:len(path)-1] {
+		child, ok := m[p].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			m[p] = child
+		}
+		m = child
+	}
+	m[path[len(path)-1]] = value
+}
+
+// copyValue deep copies maps so that merging does not modify the original.
+func copyValue(value interface{}) interface{} {
+	m, ok := toStringMap(value)
+	if !ok {
+		return value
+	}
+	res := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		res[strings.ToLower(k)] = copyValue(v)
+	}
+	return res
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
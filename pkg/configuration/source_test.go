@@ -0,0 +1,129 @@
+package conf
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createLayeredConfiguration(t *testing.T) (*Configuration, string) {
+	dir := t.TempDir()
+	writeConfigFile(t, filepath.Join(dir, "default.yml"),
+		"layer:\n  base: default\n  env: default\n  local: default\n  flag: default\n")
+	writeConfigFile(t, filepath.Join(dir, "layered.yaml"),
+		"layer:\n  env: layered\n  local: layered\n  flag: layered\n")
+	writeConfigFile(t, filepath.Join(dir, "layered.local.yaml"),
+		"layer:\n  local: local\n  flag: local\n")
+	config := NewConfiguration("layertest", "layered", []string{dir})
+	config.SetLayeredFiles(true)
+	return config, dir
+}
+
+func TestConfigurationInitialize_WithoutLayeredFiles_LoadsEnvironmentFile(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, dir := createLayeredConfiguration(t)
+	config.SetLayeredFiles(false)
+
+	// Act
+	err := config.Initialize()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("", config.GetString("layer.base"))
+	assert.Equal("layered", config.GetString("layer.local"))
+	assert.Equal("file:"+filepath.Join(dir, "layered.yaml"), config.SourceOf("layer.local"))
+}
+
+func TestConfigurationInitialize_WithStandardFiles_MergesInOrder(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, dir := createLayeredConfiguration(t)
+
+	// Act
+	err := config.Initialize()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("default", config.GetString("layer.base"))
+	assert.Equal("layered", config.GetString("layer.env"))
+	assert.Equal("local", config.GetString("layer.local"))
+	assert.Equal("file:"+filepath.Join(dir, "default.yml"), config.SourceOf("layer.base"))
+	assert.Equal("file:"+filepath.Join(dir, "layered.local.yaml"), config.SourceOf("layer.local"))
+	assert.Equal("file:"+filepath.Join(dir, "layered.local.yaml"), config.SourceOf("layer"))
+}
+
+func TestConfigurationInitialize_WithEnvAndFlags_FlagsHavePrecedence(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, _ := createLayeredConfiguration(t)
+	os.Setenv("LAYERTEST_LAYER_ENV", "env")
+	os.Setenv("LAYERTEST_LAYER_FLAG", "env")
+	defer os.Unsetenv("LAYERTEST_LAYER_ENV")
+	defer os.Unsetenv("LAYERTEST_LAYER_FLAG")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("layer.flag", "", "")
+	fs.String("layer.unset", "unset", "")
+	fs.Parse([]string{"-layer.flag=flag"})
+	config.AddSource(NewFlagSource(fs))
+
+	// Act
+	err := config.Initialize()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("env", config.GetString("layer.env"))
+	assert.Equal("flag", config.GetString("layer.flag"))
+	assert.Equal("", config.GetString("layer.unset"))
+	assert.Equal("env", config.SourceOf("layer.env"))
+	assert.Equal("flag", config.SourceOf("layer.flag"))
+}
+
+func TestConfigurationInitialize_WithAddedSources_MergesInOrder(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	reader, _ := NewReaderSource("base", "yaml", strings.NewReader("a:\n  b: reader\n  c: reader\n"))
+	config := NewConfiguration("", "", nil)
+	config.AddSource(reader, NewMapSource("overrides", map[string]interface{}{"a.c": "map"}))
+
+	// Act
+	err := config.Initialize()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("reader", config.GetString("a.b"))
+	assert.Equal("map", config.GetString("a.c"))
+	assert.Equal("base", config.SourceOf("a.b"))
+	assert.Equal("overrides", config.SourceOf("a.c"))
+	assert.Equal("", config.SourceOf("a.d"))
+}
+
+func TestConfigurationInitialize_WithMissingRequiredFile_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := NewConfiguration("", "", nil)
+	config.AddSource(NewFileSource(filepath.Join(t.TempDir(), "missing.yaml"), false))
+
+	// Act
+	err := config.Initialize()
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestConfigurationSourceOf_WithDefaultValue_ReturnsDefault(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, _ := NewConfigurationFromReader("yaml", strings.NewReader(""))
+	config.InitializeComponentConfig(&WatchTestConfig{Level: "debug"})
+
+	// Act
+	source := config.SourceOf("watch.count")
+
+	// Assert
+	assert.Equal("default", source)
+}
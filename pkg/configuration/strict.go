@@ -0,0 +1,165 @@
+package conf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the maximum number of keys suggested for an unknown key.
+const maxSuggestions = 3
+
+// SetStrict enables or disables the strict mode. In strict mode, the keys of
+// the configuration sources which are not used by any component configuration
+// (nor read with a getter) are reported by CheckUnknownKeys, and Reload
+// rejects new values containing unknown keys.
+// The environment variables and the flags are not checked.
+func (c *Configuration) SetStrict(strict bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.strict = strict
+}
+
+// IsStrict returns whether the strict mode is enabled.
+func (c *Configuration) IsStrict() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.strict
+}
+
+// CheckUnknownKeys returns a *ConfigError listing the keys which were not used
+// since the configuration was initialized, with suggestions of known keys
+// close to them. It is meant to be called once all the component
+// configurations are initialized and always returns nil when the strict mode
+// is disabled.
+func (c *Configuration) CheckUnknownKeys() error {
+	c.ensureInitialized()
+	if !c.IsStrict() {
+		return nil
+	}
+	unknown := c.UnknownKeys()
+	if len(unknown) == 0 {
+		return nil
+	}
+	known := c.knownKeys()
+	configErr := &ConfigError{}
+	for _, key := range unknown {
+		reason := "unknown key"
+		if suggestions := suggestKeys(key, known); len(suggestions) > 0 {
+			reason += fmt.Sprintf(", did you mean [%s]?", strings.Join(suggestions, "], ["))
+		}
+		configErr.Errors = append(configErr.Errors, FieldError{
+			Key:    key,
+			Source: c.sourceOfPath(key),
+			Reason: reason,
+		})
+	}
+	return configErr
+}
+
+// UnknownKeys returns the sorted keys of the configuration sources which were
+// not used by any component configuration nor read with a getter.
+func (c *Configuration) UnknownKeys() []string {
+	c.ensureInitialized()
+	keys := map[string]bool{}
+	for _, s := range c.getLoaded() {
+		for key := range s.keys {
+			if !c.isUsedKey(key) {
+				keys[key] = true
+			}
+		}
+	}
+	res := make([]string, 0, len(keys))
+	for key := range keys {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// isUsedKey returns whether the given key or one of its parents was used.
+func (c *Configuration) isUsedKey(key string) bool {
+	path := strings.Split(key, ".")
+	for i := range path {
+		if c.usedKeys.has(strings.Join(path[:i+1], ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// knownKeys returns the keys which were used or have a default value.
+func (c *Configuration) knownKeys() []string {
+	keys := map[string]bool{}
+	for _, key := range c.usedKeys.list() {
+		keys[key] = true
+	}
+	for _, key := range c.defaults.keysWithPrefix("") {
+		keys[strings.ToLower(key)] = true
+	}
+	res := make([]string, 0, len(keys))
+	for key := range keys {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// suggestKeys returns the known keys closest to the given key, if they are
+// close enough to be a likely typo.
+func suggestKeys(key string, known []string) []string {
+	maxDistance := len(key) / 4
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	type suggestion struct {
+		key      string
+		distance int
+	}
+	var suggestions []suggestion
+	for _, k := range known {
+		if d := levenshtein(key, k); d <= maxDistance {
+			suggestions = append(suggestions, suggestion{key: k, distance: d})
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].distance < suggestions[j].distance
+	})
+	var res []string
+	for i := 0; i < len(suggestions) && i < maxSuggestions; i++ {
+		res = append(res, suggestions[i].key)
+	}
+	return res
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func minInt(values ...int) int {
+	res := values[0]
+	for _, v := range values[1:] {
+		if v < res {
+			res = v
+		}
+	}
+	return res
+}
@@ -0,0 +1,107 @@
+package conf
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type StrictTestConfig struct {
+	Host     string         `configkey:"database.host"`
+	Lifetime time.Duration  `configkey:"database.connection_lifetime,duration" default:"1h"`
+	Limits   map[string]int `configkey:"database.limits"`
+}
+
+const strictTestContent = `
+database:
+  host: db
+  connection_lifetme: 2h
+  limits:
+    read: 1
+unused: true
+`
+
+func TestConfigurationCheckUnknownKeys_WithTypo_ReturnsSuggestion(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, strictTestContent)
+	config.SetStrict(true)
+	strictConfig := &StrictTestConfig{}
+	assert.NoError(config.InitializeComponentConfig(strictConfig))
+
+	// Act
+	err := config.CheckUnknownKeys()
+
+	// Assert
+	var configErr *ConfigError
+	if assert.True(errors.As(err, &configErr)) && assert.Len(configErr.Errors, 2) {
+		assert.Equal(FieldError{
+			Key:    "database.connection_lifetme",
+			Source: "reader:yaml",
+			Reason: "unknown key, did you mean [database.connection_lifetime]?",
+		}, configErr.Errors[0])
+		assert.Equal(FieldError{
+			Key:    "unused",
+			Source: "reader:yaml",
+			Reason: "unknown key",
+		}, configErr.Errors[1])
+	}
+}
+
+func TestConfigurationCheckUnknownKeys_WithoutStrictMode_ReturnsNil(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, strictTestContent)
+	strictConfig := &StrictTestConfig{}
+	assert.NoError(config.InitializeComponentConfig(strictConfig))
+
+	// Act
+	err := config.CheckUnknownKeys()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]string{"database.connection_lifetme", "unused"}, config.UnknownKeys())
+}
+
+func TestConfigurationCheckUnknownKeys_WithGetterAndSubConfig_ReturnsNil(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := createDecodeConfiguration(t, "server:\n  address: localhost\ndatabase:\n  host: db\n")
+	config.SetStrict(true)
+	config.GetString("server.address")
+	subConfig := &struct {
+		Host string `configkey:"host"`
+	}{}
+	assert.NoError(config.Sub("database").InitializeComponentConfig(subConfig))
+
+	// Act
+	err := config.CheckUnknownKeys()
+
+	// Assert
+	assert.NoError(err)
+}
+
+func TestConfigurationReload_WithStrictModeAndUnknownKey_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, path, "database:\n  host: db\n")
+	config := NewConfiguration("app", "test", nil)
+	config.AddSource(NewFileSource(path, false))
+	assert.NoError(config.Initialize())
+	config.SetStrict(true)
+	strictConfig := &StrictTestConfig{}
+	assert.NoError(config.Subscribe(strictConfig, func(oldConf, newConf interface{}) {}))
+	writeConfigFile(t, path, "database:\n  hots: db2\n")
+
+	// Act
+	err := config.Reload()
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "did you mean [database.host]?")
+	assert.Equal("db", config.GetString("database.host"))
+}
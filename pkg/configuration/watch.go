@@ -0,0 +1,294 @@
+package conf
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// ChangeHandler is called after a successful reload with the previous and the
+// new value of a subscribed component configuration. Both values are pointers
+// to the type given to Subscribe.
+type ChangeHandler func(oldConf, newConf interface{})
+
+// subscription is a component configuration registered for hot reload.
+type subscription struct {
+	current interface{}
+	handler ChangeHandler
+}
+
+// defaultValues records the default values set by component configurations so
+// that they can be applied again when the configuration is reloaded.
+type defaultValues struct {
+	mu     sync.Mutex
+	keys   []string
+	values map[string]interface{}
+}
+
+func newDefaultValues() *defaultValues {
+	return &defaultValues{values: map[string]interface{}{}}
+}
+
+func (d *defaultValues) set(key string, value interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.values[key] = value
+}
+
+func (d *defaultValues) has(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, k := range d.keys {
+		k = strings.ToLower(k)
+		if k == key || strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *defaultValues) get(key string) interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, k := range d.keys {
+		if strings.ToLower(k) == key {
+			return d.values[k]
+		}
+	}
+	return nil
+}
+
+// keysWithPrefix returns the lower-cased keys starting with the given prefix,
+// the prefix being removed.
+func (d *defaultValues) keysWithPrefix(prefix string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := make([]string, 0, len(d.keys))
+	for _, k := range d.keys {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, prefix) {
+			res = append(res, strings.TrimPrefix(k, prefix))
+		}
+	}
+	return res
+}
+
+func (d *defaultValues) applyTo(v *viper.Viper) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, key := range d.keys {
+		v.SetDefault(key, d.values[key])
+	}
+}
+
+// watcher holds the state of a running file watch.
+type watcher struct {
+	fsWatcher *fsnotify.Watcher
+	done      chan struct{}
+}
+
+// Subscribe registers a component configuration for hot reload.
+// compConf must be a pointer to a component configuration struct that was
+// initialized with InitializeComponentConfig. On every reload a new value of
+// the same type is initialized and validated, and handler is called with the
+// old and new values if they differ. If any subscribed configuration fails to
+// initialize, the reload is aborted and the previous values are kept.
+func (c *Configuration) Subscribe(compConf interface{}, handler ChangeHandler) error {
+	c.ensureInitialized()
+	t := reflect.TypeOf(compConf)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("component configuration must be a pointer to a struct, got %v", t)
+	}
+	if handler == nil {
+		return errors.New("change handler is missing")
+	}
+
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	c.subscriptions = append(c.subscriptions, &subscription{
+		current: compConf,
+		handler: handler,
+	})
+	return nil
+}
+
+// Reload loads the sources of the configuration again and notifies the
+// subscribers of the values that changed. The configuration is left untouched
+// if a source cannot be loaded, if a subscribed component configuration is
+// invalid or, in strict mode, if the new values contain unknown keys.
+// The handlers are called once the configuration is updated, without holding
+// its lock, so that they can use the configuration (e.g. Subscribe).
+func (c *Configuration) Reload() error {
+	changes, err := c.reload()
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		change.handler(change.oldConf, change.newConf)
+	}
+	return nil
+}
+
+// configChange is a change of a subscribed component configuration.
+type configChange struct {
+	handler          ChangeHandler
+	oldConf, newConf interface{}
+}
+
+// reload loads the sources again and returns the changes of the subscribed
+// component configurations to notify.
+func (c *Configuration) reload() ([]configChange, error) {
+	c.ensureInitialized()
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	v, loaded, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := c.withViper(v, loaded)
+	updated := make([]interface{}, len(c.subscriptions))
+	for i, sub := range c.subscriptions {
+		newConf := reflect.New(reflect.TypeOf(sub.current).Elem()).Interface()
+		if err := snapshot.InitializeComponentConfig(newConf); err != nil {
+			return nil, errors.Wrapf(err, "failed to reload %T", sub.current)
+		}
+		updated[i] = newConf
+	}
+	if err := snapshot.CheckUnknownKeys(); err != nil {
+		return nil, err
+	}
+
+	c.setState(v, loaded)
+
+	var changes []configChange
+	for i, sub := range c.subscriptions {
+		oldConf := sub.current
+		if reflect.DeepEqual(oldConf, updated[i]) {
+			continue
+		}
+		sub.current = updated[i]
+		changes = append(changes, configChange{handler: sub.handler, oldConf: oldConf, newConf: updated[i]})
+	}
+	return changes, nil
+}
+
+// Watch starts watching the files of the file sources and reloads the
+// configuration each time one of them is written, created or replaced (e.g.
+// kubernetes ConfigMap update).
+// Errors occurring during a reload are passed to onError if it is not nil.
+func (c *Configuration) Watch(onError func(error)) error {
+	c.ensureInitialized()
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	if c.watcher != nil {
+		return errors.New("configuration is already watched")
+	}
+
+	var files []*FileSource
+	dirs := map[string]bool{}
+	for _, source := range c.sources {
+		if fs, ok := source.(*FileSource); ok {
+			files = append(files, fs)
+			for _, dir := range fs.dirs() {
+				dirs[dir] = true
+			}
+		}
+	}
+	if len(files) == 0 {
+		return errors.New("configuration was not read from a file and cannot be watched")
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "failed to create file watcher")
+	}
+	// directories are watched to also catch atomic saves and symlink swaps.
+	for dir := range dirs {
+		if err := fsWatcher.Add(dir); err != nil {
+			fsWatcher.Close()
+			return errors.Wrapf(err, "failed to watch directory [%s]", dir)
+		}
+	}
+
+	w := &watcher{fsWatcher: fsWatcher, done: make(chan struct{})}
+	c.watcher = w
+	go c.watch(w, files, onError)
+	return nil
+}
+
+// StopWatching stops watching the configuration files.
+func (c *Configuration) StopWatching() error {
+	c.reloadMu.Lock()
+	w := c.watcher
+	c.watcher = nil
+	c.reloadMu.Unlock()
+
+	if w == nil {
+		return nil
+	}
+	err := w.fsWatcher.Close()
+	<-w.done
+	if err != nil {
+		return errors.Wrap(err, "failed to close file watcher")
+	}
+	return nil
+}
+
+func (c *Configuration) watch(w *watcher, files []*FileSource, onError func(error)) {
+	defer close(w.done)
+	realPaths := resolveRealPaths(files)
+	const watchedOps = fsnotify.Write | fsnotify.Create | fsnotify.Remove | fsnotify.Rename
+
+	for {
+		select {
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return
+			}
+			currentPaths := resolveRealPaths(files)
+			changed := false
+			for i, fs := range files {
+				if (event.Op&watchedOps != 0 && fs.matches(event.Name)) ||
+					currentPaths[i] != realPaths[i] {
+					changed = true
+				}
+			}
+			realPaths = currentPaths
+			if !changed {
+				continue
+			}
+			if err := c.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			if onError != nil {
+				onError(errors.Wrap(err, "file watcher error"))
+			}
+		}
+	}
+}
+
+// resolveRealPaths returns the paths of the given files after evaluation of
+// the symbolic links.
+func resolveRealPaths(files []*FileSource) []string {
+	res := make([]string, len(files))
+	for i, fs := range files {
+		if path, ok := fs.resolve(); ok {
+			res[i], _ = filepath.EvalSymlinks(path)
+		}
+	}
+	return res
+}
@@ -0,0 +1,169 @@
+package conf
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type WatchTestConfig struct {
+	Level string `configkey:"watch.level" validate:"oneof=debug info"`
+	Count int    `configkey:"watch.count" default:"1"`
+}
+
+func createWatchedConfiguration(t *testing.T, content string) (*Configuration, string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "watch.yaml")
+	writeConfigFile(t, path, content)
+	c := NewConfiguration("watch", "watch", []string{dir})
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("reading configuration failed: %s", err)
+	}
+	return c, path
+}
+
+func writeConfigFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("writing configuration failed: %s", err)
+	}
+}
+
+func TestConfigurationReload_WithChangedValue_NotifiesSubscriber(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, path := createWatchedConfiguration(t, "watch:\n  level: debug\n")
+	watchConfig := &WatchTestConfig{}
+	config.InitializeComponentConfig(watchConfig)
+	var oldConf, newConf *WatchTestConfig
+	config.Subscribe(watchConfig, func(o, n interface{}) {
+		oldConf = o.(*WatchTestConfig)
+		newConf = n.(*WatchTestConfig)
+	})
+	writeConfigFile(t, path, "watch:\n  level: info\n")
+
+	// Act
+	err := config.Reload()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(&WatchTestConfig{Level: "debug", Count: 1}, oldConf)
+	assert.Equal(&WatchTestConfig{Level: "info", Count: 1}, newConf)
+	assert.Equal("info", config.GetString("watch.level"))
+}
+
+func TestConfigurationReload_WithUnchangedValue_DoesNotNotify(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, _ := createWatchedConfiguration(t, "watch:\n  level: debug\n")
+	watchConfig := &WatchTestConfig{}
+	config.InitializeComponentConfig(watchConfig)
+	called := false
+	config.Subscribe(watchConfig, func(o, n interface{}) { called = true })
+
+	// Act
+	err := config.Reload()
+
+	// Assert
+	assert.NoError(err)
+	assert.False(called)
+}
+
+func TestConfigurationReload_WithInvalidValue_KeepsPreviousValues(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, path := createWatchedConfiguration(t, "watch:\n  level: debug\n")
+	watchConfig := &WatchTestConfig{}
+	config.InitializeComponentConfig(watchConfig)
+	called := false
+	config.Subscribe(watchConfig, func(o, n interface{}) { called = true })
+	writeConfigFile(t, path, "watch:\n  level: trace\n")
+
+	// Act
+	err := config.Reload()
+
+	// Assert
+	assert.Error(err)
+	assert.False(called)
+	assert.Equal("debug", config.GetString("watch.level"))
+}
+
+func TestConfigurationWatch_FromReader_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, _ := NewConfigurationFromReader("yaml", strings.NewReader(""))
+
+	// Act
+	err := config.Watch(nil)
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestConfigurationSubscribe_WithValue_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, _ := createWatchedConfiguration(t, "watch:\n  level: debug\n")
+
+	// Act
+	err := config.Subscribe(WatchTestConfig{}, func(o, n interface{}) {})
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestConfigurationWatch_WithFileWritten_NotifiesSubscriber(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, path := createWatchedConfiguration(t, "watch:\n  level: debug\n")
+	watchConfig := &WatchTestConfig{}
+	config.InitializeComponentConfig(watchConfig)
+	changed := make(chan *WatchTestConfig, 10)
+	config.Subscribe(watchConfig, func(o, n interface{}) { changed <- n.(*WatchTestConfig) })
+	err := config.Watch(nil)
+	defer config.StopWatching()
+
+	// Act
+	writeConfigFile(t, path, "watch:\n  level: info\n  count: 2\n")
+
+	// Assert
+	assert.NoError(err)
+	select {
+	case newConf := <-changed:
+		assert.Equal(&WatchTestConfig{Level: "info", Count: 2}, newConf)
+	case <-time.After(5 * time.Second):
+		t.Fatal("configuration change was not notified")
+	}
+}
+
+func TestConfigurationReload_WithHandlerUsingConfiguration_DoesNotDeadlock(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, path := createWatchedConfiguration(t, "watch:\n  level: debug\n")
+	watchConfig := &WatchTestConfig{}
+	config.InitializeComponentConfig(watchConfig)
+	var subscribeErr, reloadErr error
+	config.Subscribe(watchConfig, func(oldConf, newConf interface{}) {
+		subscribeErr = config.Subscribe(newConf, func(oldConf, newConf interface{}) {})
+		reloadErr = config.Reload()
+	})
+	writeConfigFile(t, path, "watch:\n  level: info\n")
+
+	// Act
+	done := make(chan error)
+	go func() { done <- config.Reload() }()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload deadlocked")
+	}
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(subscribeErr)
+	assert.NoError(reloadErr)
+}
@@ -0,0 +1,265 @@
+package orm
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// ErrorCategory is the category of a database error, independent of the
+// database.
+type ErrorCategory int
+
+const (
+	// UnknownError is the category of the errors which are not classified.
+	UnknownError ErrorCategory = iota
+	// RecordNotFound is the category of gorm.ErrRecordNotFound.
+	RecordNotFound
+	// UniqueViolation is the violation of a unique or primary key constraint.
+	UniqueViolation
+	// ForeignKeyViolation is the violation of a foreign key constraint.
+	ForeignKeyViolation
+	// CheckViolation is the violation of a check constraint.
+	CheckViolation
+	// NotNullViolation is the violation of a not null constraint.
+	NotNullViolation
+	// SerializationFailure is a serialization failure or a deadlock of
+	// concurrent transactions (or a locked sqlite database), the transaction
+	// can be retried.
+	SerializationFailure
+	// ConnectionLost is the loss of the connection to the database.
+	ConnectionLost
+	// Timeout is the expiry of a deadline or of a statement or lock timeout.
+	Timeout
+)
+
+// Postgres error codes, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html.
+const (
+	pgUniqueViolation      = "23505"
+	pgForeignKeyViolation  = "23503"
+	pgCheckViolation       = "23514"
+	pgNotNullViolation     = "23502"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+	pgQueryCanceled        = "57014"
+	pgLockNotAvailable     = "55P03"
+	pgAdminShutdown        = "57P01"
+	pgCrashShutdown        = "57P02"
+	pgCannotConnectNow     = "57P03"
+	// pgConnectionException is the class of the connection errors.
+	pgConnectionException = "08"
+)
+
+var categoryNames = map[ErrorCategory]string{
+	UnknownError:         "unknown error",
+	RecordNotFound:       "record not found",
+	UniqueViolation:      "unique violation",
+	ForeignKeyViolation:  "foreign key violation",
+	CheckViolation:       "check violation",
+	NotNullViolation:     "not null violation",
+	SerializationFailure: "serialization failure",
+	ConnectionLost:       "connection lost",
+	Timeout:              "timeout",
+}
+
+// String returns the name of the category.
+func (c ErrorCategory) String() string {
+	if name, ok := categoryNames[c]; ok {
+		return name
+	}
+	return categoryNames[UnknownError]
+}
+
+// HTTPStatus returns the HTTP status code of the errors of the category.
+func (c ErrorCategory) HTTPStatus() int {
+	switch c {
+	case RecordNotFound:
+		return http.StatusNotFound
+	case UniqueViolation, SerializationFailure:
+		return http.StatusConflict
+	case ForeignKeyViolation, CheckViolation, NotNullViolation:
+		return http.StatusBadRequest
+	case ConnectionLost, Timeout:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCCode returns the gRPC status code of the errors of the category.
+func (c ErrorCategory) GRPCCode() codes.Code {
+	switch c {
+	case RecordNotFound:
+		return codes.NotFound
+	case UniqueViolation:
+		return codes.AlreadyExists
+	case ForeignKeyViolation:
+		return codes.FailedPrecondition
+	case CheckViolation, NotNullViolation:
+		return codes.InvalidArgument
+	case SerializationFailure:
+		return codes.Aborted
+	case ConnectionLost:
+		return codes.Unavailable
+	case Timeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
+
+// DBError is a classified database error.
+type DBError struct {
+	Category ErrorCategory
+	// Constraint is the name of the violated constraint, if known. sqlite
+	// reports the columns of unique and not null constraints instead (e.g.
+	// "users.email").
+	Constraint string
+	// Err is the original error.
+	Err error
+}
+
+// Error returns the message of the original error.
+func (e *DBError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the original error.
+func (e *DBError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatus returns the HTTP status code of the error.
+func (e *DBError) HTTPStatus() int {
+	return e.Category.HTTPStatus()
+}
+
+// GRPCCode returns the gRPC status code of the error.
+func (e *DBError) GRPCCode() codes.Code {
+	return e.Category.GRPCCode()
+}
+
+// GRPCStatus returns the gRPC status of the error, with the name of its
+// category as message so that no database detail is sent to the clients.
+// status.FromError uses it, a DBError returned by a gRPC handler being sent
+// with its status code.
+func (e *DBError) GRPCStatus() *status.Status {
+	return status.New(e.GRPCCode(), e.Category.String())
+}
+
+// ClassifyError classifies an error returned by gorm, a postgres or an sqlite
+// database. It returns nil if err is nil, and a DBError with the UnknownError
+// category if the error is not recognized.
+// ex.)
+//	if dbErr := orm.ClassifyError(err); dbErr.Category == orm.UniqueViolation {
+//		c.JSON(dbErr.HTTPStatus(), gin.H{"error": "email already used"})
+//	}
+func ClassifyError(err error) *DBError {
+	if err == nil {
+		return nil
+	}
+	var dbErr *DBError
+	if errors.As(err, &dbErr) {
+		return dbErr
+	}
+	res := &DBError{Err: err}
+	var pgErr *pgconn.PgError
+	var sqliteErr sqlite3.Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		res.Category = RecordNotFound
+	case errors.As(err, &pgErr):
+		res.Category, res.Constraint = classifyPgError(pgErr)
+	case errors.As(err, &sqliteErr):
+		res.Category, res.Constraint = classifySqliteError(sqliteErr)
+	case isTimeout(err):
+		res.Category = Timeout
+	case isConnectionLost(err):
+		res.Category = ConnectionLost
+	}
+	return res
+}
+
+// ErrorCategoryOf returns the category of the given error, see ClassifyError.
+func ErrorCategoryOf(err error) ErrorCategory {
+	if err == nil {
+		return UnknownError
+	}
+	return ClassifyError(err).Category
+}
+
+func classifyPgError(err *pgconn.PgError) (ErrorCategory, string) {
+	switch err.Code {
+	case pgUniqueViolation:
+		return UniqueViolation, err.ConstraintName
+	case pgForeignKeyViolation:
+		return ForeignKeyViolation, err.ConstraintName
+	case pgCheckViolation:
+		return CheckViolation, err.ConstraintName
+	case pgNotNullViolation:
+		return NotNullViolation, err.ColumnName
+	case pgSerializationFailure, pgDeadlockDetected:
+		return SerializationFailure, ""
+	case pgQueryCanceled, pgLockNotAvailable:
+		return Timeout, ""
+	case pgAdminShutdown, pgCrashShutdown, pgCannotConnectNow:
+		return ConnectionLost, ""
+	}
+	if strings.HasPrefix(err.Code, pgConnectionException) {
+		return ConnectionLost, ""
+	}
+	return UnknownError, ""
+}
+
+func classifySqliteError(err sqlite3.Error) (ErrorCategory, string) {
+	switch err.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return UniqueViolation, sqliteConstraint(err)
+	case sqlite3.ErrConstraintForeignKey:
+		return ForeignKeyViolation, ""
+	case sqlite3.ErrConstraintCheck:
+		return CheckViolation, sqliteConstraint(err)
+	case sqlite3.ErrConstraintNotNull:
+		return NotNullViolation, sqliteConstraint(err)
+	}
+	switch err.Code {
+	case sqlite3.ErrBusy, sqlite3.ErrLocked:
+		return SerializationFailure, ""
+	}
+	return UnknownError, ""
+}
+
+// sqliteConstraint returns the constraint of an sqlite constraint error
+// message, e.g. "users.email" for "UNIQUE constraint failed: users.email".
+func sqliteConstraint(err sqlite3.Error) string {
+	message := err.Error()
+	if i := strings.Index(message, "constraint failed: "); i >= 0 {
+		return message[i+len("constraint failed: "):]
+	}
+	return ""
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func isConnectionLost(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
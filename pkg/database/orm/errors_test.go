@@ -0,0 +1,193 @@
+package orm_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"testing"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
+	"github.com/cryptogarageinc/server-common-go/test"
+	"github.com/jackc/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+type TestConstrainedItem struct {
+	ID    uint
+	Email string `gorm:"uniqueIndex;not null"`
+	Count int    `gorm:"check:count_positive,count >= 0"`
+}
+
+func TestClassifyError_SqliteUniqueViolation_ReturnsUniqueViolation(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestConstrainedItem{})
+	defer ormInstance.Finalize()
+	ormInstance.GetDB().Create(&TestConstrainedItem{Email: "a@example.com"})
+
+	// Act
+	err := ormInstance.GetDB().Create(&TestConstrainedItem{Email: "a@example.com"}).Error
+	dbErr := orm.ClassifyError(err)
+
+	// Assert
+	assert.Equal(orm.UniqueViolation, dbErr.Category)
+	assert.Equal("test_constrained_items.email", dbErr.Constraint)
+	assert.Equal(http.StatusConflict, dbErr.HTTPStatus())
+	assert.Equal(codes.AlreadyExists, dbErr.GRPCCode())
+}
+
+func TestClassifyError_SqliteCheckViolation_ReturnsCheckViolation(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestConstrainedItem{})
+	defer ormInstance.Finalize()
+
+	// Act
+	err := ormInstance.GetDB().Create(&TestConstrainedItem{Email: "a@example.com", Count: -1}).Error
+	dbErr := orm.ClassifyError(err)
+
+	// Assert
+	assert.Equal(orm.CheckViolation, dbErr.Category)
+	assert.Equal("count_positive", dbErr.Constraint)
+	assert.Equal(http.StatusBadRequest, dbErr.HTTPStatus())
+}
+
+func TestClassifyError_SqliteNotNullViolation_ReturnsNotNullViolation(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestConstrainedItem{})
+	defer ormInstance.Finalize()
+
+	// Act
+	err := ormInstance.GetDB().Exec("INSERT INTO test_constrained_items (count) VALUES (1)").Error
+	dbErr := orm.ClassifyError(err)
+
+	// Assert
+	assert.Equal(orm.NotNullViolation, dbErr.Category)
+	assert.Equal("test_constrained_items.email", dbErr.Constraint)
+	assert.Equal(codes.InvalidArgument, dbErr.GRPCCode())
+}
+
+func TestClassifyError_SqliteErrors_ReturnsCategory(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      sqlite3.Error
+		expected orm.ErrorCategory
+	}{
+		{"foreign key", sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintForeignKey}, orm.ForeignKeyViolation},
+		{"primary key", sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey}, orm.UniqueViolation},
+		{"busy", sqlite3.Error{Code: sqlite3.ErrBusy}, orm.SerializationFailure},
+		{"other", sqlite3.Error{Code: sqlite3.ErrCorrupt}, orm.UnknownError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			category := orm.ErrorCategoryOf(tt.err)
+
+			// Assert
+			assert.Equal(t, tt.expected, category)
+		})
+	}
+}
+
+func TestClassifyError_PostgresErrors_ReturnsCategory(t *testing.T) {
+	tests := []struct {
+		code       string
+		expected   orm.ErrorCategory
+		httpStatus int
+		grpcCode   codes.Code
+	}{
+		{"23505", orm.UniqueViolation, http.StatusConflict, codes.AlreadyExists},
+		{"23503", orm.ForeignKeyViolation, http.StatusBadRequest, codes.FailedPrecondition},
+		{"23514", orm.CheckViolation, http.StatusBadRequest, codes.InvalidArgument},
+		{"23502", orm.NotNullViolation, http.StatusBadRequest, codes.InvalidArgument},
+		{"40001", orm.SerializationFailure, http.StatusConflict, codes.Aborted},
+		{"40P01", orm.SerializationFailure, http.StatusConflict, codes.Aborted},
+		{"08006", orm.ConnectionLost, http.StatusServiceUnavailable, codes.Unavailable},
+		{"57P01", orm.ConnectionLost, http.StatusServiceUnavailable, codes.Unavailable},
+		{"57014", orm.Timeout, http.StatusServiceUnavailable, codes.DeadlineExceeded},
+		{"42P01", orm.UnknownError, http.StatusInternalServerError, codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			// Arrange
+			assert := assert.New(t)
+			err := errors.Wrap(&pgconn.PgError{Code: tt.code, ConstraintName: "constraint"}, "failed")
+
+			// Act
+			dbErr := orm.ClassifyError(err)
+
+			// Assert
+			assert.Equal(tt.expected, dbErr.Category)
+			assert.Equal(tt.httpStatus, dbErr.HTTPStatus())
+			assert.Equal(tt.grpcCode, dbErr.GRPCCode())
+			assert.Equal(err, dbErr.Err)
+		})
+	}
+}
+
+func TestClassifyError_PostgresUniqueViolation_ReturnsConstraint(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	err := &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"}
+
+	// Act
+	dbErr := orm.ClassifyError(err)
+
+	// Assert
+	assert.Equal("users_email_key", dbErr.Constraint)
+	assert.True(errors.Is(dbErr, err))
+}
+
+func TestClassifyError_GenericErrors_ReturnsCategory(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected orm.ErrorCategory
+	}{
+		{"not found", gorm.ErrRecordNotFound, orm.RecordNotFound},
+		{"bad connection", driver.ErrBadConn, orm.ConnectionLost},
+		{"deadline", errors.Wrap(context.DeadlineExceeded, "query"), orm.Timeout},
+		{"other", errors.New("error"), orm.UnknownError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			category := orm.ErrorCategoryOf(tt.err)
+
+			// Assert
+			assert.Equal(t, tt.expected, category)
+		})
+	}
+}
+
+func TestClassifyError_Nil_ReturnsNil(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	dbErr := orm.ClassifyError(nil)
+
+	// Assert
+	assert.Nil(dbErr)
+}
+
+func TestDBError_GRPCStatus_HidesDetails(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	err := error(orm.ClassifyError(&pgconn.PgError{Code: "23505", Message: "duplicate key value"}))
+
+	// Act
+	s, ok := status.FromError(err)
+
+	// Assert
+	assert.True(ok)
+	assert.Equal(codes.AlreadyExists, s.Code())
+	assert.Equal("unique violation", s.Message())
+}
@@ -1,14 +1,79 @@
 package orm
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
 )
 
+// SchemaMigrationsTable is the name of the table tracking the applied
+// versioned migrations.
+const SchemaMigrationsTable = "schema_migrations"
+
+// Migration is a versioned migration. Migrations are applied in the order of
+// their IDs, which should therefore be sortable, e.g. "0001_create_users" or
+// "20210601120000_add_user_email".
+// The Up and Down functions take precedence over the UpSQL and DownSQL
+// statements. A migration without Down function nor DownSQL cannot be rolled
+// back.
+type Migration struct {
+	ID      string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+	UpSQL   string
+	DownSQL string
+}
+
+// Checksum returns the checksum of the SQL statements of the migration, which
+// is stored when the migration is applied to detect later modifications. The
+// checksum of a migration made of functions is empty.
+func (m *Migration) Checksum() string {
+	if m.Up != nil || (m.UpSQL == "" && m.DownSQL == "") {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(m.UpSQL + "\x00" + m.DownSQL))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *Migration) canRollback() bool {
+	return m.Down != nil || m.DownSQL != ""
+}
+
+// SchemaMigration is a record of the schema_migrations table.
+type SchemaMigration struct {
+	ID        string `gorm:"primaryKey"`
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// TableName returns SchemaMigrationsTable.
+func (SchemaMigration) TableName() string {
+	return SchemaMigrationsTable
+}
+
+// MigrationStatus is the status of a versioned migration.
+type MigrationStatus struct {
+	ID        string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is true if the migration was modified since it was applied.
+	Modified bool
+	// Missing is true if the migration was applied but is not registered.
+	Missing bool
+}
+
 // Migrator is a structure used to perform DB migrations.
 type Migrator struct {
 	orm         *ORM
 	initialized bool
 	models      []interface{}
+	migrations  []*Migration
+	// db replaces the orm DB in dry run mode.
+	db *gorm.DB
 }
 
 // NewMigrator creates a new Migrator class.
@@ -21,12 +86,34 @@ func NewMigrator(orm *ORM, models ...interface{}) *Migrator {
 	}
 }
 
-// Initialize performs the migrations for the models handled by the Migrator.
-func (m *Migrator) Initialize() error {
-	for _, model := range m.models {
-		if err := m.orm.GetDB().AutoMigrate(model); err != nil {
-			return errors.Errorf("migration failed for [%v]", model)
+// Register registers versioned migrations.
+// ex.) migrator.Register(&orm.Migration{ID: "0001_backfill_names", Up: ...})
+func (m *Migrator) Register(migrations ...*Migration) error {
+	for _, migration := range migrations {
+		if migration.ID == "" {
+			return errors.New("migration ID is empty")
+		}
+		if migration.Up == nil && migration.UpSQL == "" {
+			return errors.Errorf("migration [%s] has no up function nor statements", migration.ID)
 		}
+		if m.findMigration(migration.ID) != nil {
+			return errors.Errorf("migration [%s] is registered twice", migration.ID)
+		}
+		m.migrations = append(m.migrations, migration)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].ID < m.migrations[j].ID
+	})
+	return nil
+}
+
+// Initialize performs the migrations for the models handled by the Migrator,
+// and then applies the pending versioned migrations. The migrations are
+// performed while holding the migration lock, instances started concurrently
+// waiting for the first one to complete the migrations.
+func (m *Migrator) Initialize() error {
+	if err := m.withLock(m.migrate); err != nil {
+		return err
 	}
 
 	m.initialized = true
@@ -34,7 +121,300 @@ func (m *Migrator) Initialize() error {
 	return nil
 }
 
+// migrate performs the migrations of the models and the versioned migrations.
+func (m *Migrator) migrate() error {
+	for _, model := range m.models {
+		if err := m.getDB().AutoMigrate(model); err != nil {
+			return errors.Errorf("migration failed for [%v]", model)
+		}
+	}
+	if len(m.migrations) > 0 {
+		return m.up()
+	}
+	return nil
+}
+
 // IsInitialized returns whether the Migrator is initialized.
 func (m *Migrator) IsInitialized() bool {
 	return m.initialized
 }
+
+// Up applies all the pending versioned migrations.
+func (m *Migrator) Up() error {
+	return m.withLock(m.up)
+}
+
+func (m *Migrator) up() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	if err := m.checkModified(applied); err != nil {
+		return err
+	}
+	for id := range applied {
+		if m.findMigration(id) == nil {
+			m.orm.log.Logger.Warnf("applied migration [%s] is not registered, the database may be more recent than the application", id)
+		}
+	}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.ID]; !ok {
+			if err := m.apply(migration); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Down rolls back the last n applied versioned migrations.
+func (m *Migrator) Down(n int) error {
+	return m.withLock(func() error { return m.down(n) })
+}
+
+func (m *Migrator) down(n int) error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	if err := m.checkModified(applied); err != nil {
+		return err
+	}
+	ids := sortedIDs(applied)
+	for i := len(ids) - 1; i >= 0 && n > 0; i-- {
+		if err := m.rollback(ids[i]); err != nil {
+			return err
+		}
+		n--
+	}
+	return nil
+}
+
+// To applies or rolls back the versioned migrations so that the migrations up
+// to the one with the given ID (included) are applied. An empty ID rolls back
+// all the migrations.
+func (m *Migrator) To(id string) error {
+	if id != "" && m.findMigration(id) == nil {
+		return errors.Errorf("unknown migration [%s]", id)
+	}
+	return m.withLock(func() error { return m.to(id) })
+}
+
+func (m *Migrator) to(id string) error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	if err := m.checkModified(applied); err != nil {
+		return err
+	}
+	ids := sortedIDs(applied)
+	for i := len(ids) - 1; i >= 0; i-- {
+		if ids[i] > id {
+			if err := m.rollback(ids[i]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.ID]; !ok && migration.ID <= id {
+			if err := m.apply(migration); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Force records the versioned migrations up to the one with the given ID
+// (included) as applied and the following ones as not applied, without
+// applying nor rolling back any migration. It is used to repair the
+// schema_migrations table after a migration was fixed manually. An empty ID
+// records all the migrations as not applied.
+func (m *Migrator) Force(id string) error {
+	if id != "" && m.findMigration(id) == nil {
+		return errors.Errorf("unknown migration [%s]", id)
+	}
+	return m.withLock(func() error {
+		if err := m.createTable(); err != nil {
+			return err
+		}
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+		return m.getDB().Transaction(func(tx *gorm.DB) error {
+			for appliedID := range applied {
+				if appliedID > id {
+					if err := tx.Delete(&SchemaMigration{ID: appliedID}).Error; err != nil {
+						return err
+					}
+				}
+			}
+			for _, migration := range m.migrations {
+				if migration.ID > id {
+					continue
+				}
+				record := &SchemaMigration{
+					ID:        migration.ID,
+					Checksum:  migration.Checksum(),
+					AppliedAt: time.Now().UTC(),
+				}
+				if previous, ok := applied[migration.ID]; ok {
+					record.AppliedAt = previous.AppliedAt
+				}
+				if err := tx.Save(record).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Status returns the status of the registered versioned migrations and of the
+// applied migrations which are not registered, ordered by ID.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var res []MigrationStatus
+	for _, migration := range m.migrations {
+		status := MigrationStatus{ID: migration.ID}
+		if record, ok := applied[migration.ID]; ok {
+			status.Applied = true
+			status.AppliedAt = record.AppliedAt
+			status.Modified = record.Checksum != migration.Checksum()
+		}
+		res = append(res, status)
+	}
+	for id, record := range applied {
+		if m.findMigration(id) == nil {
+			res = append(res, MigrationStatus{ID: id, Applied: true, AppliedAt: record.AppliedAt, Missing: true})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+// apply applies a migration and records it in a single transaction, creating
+// the schema_migrations table if needed.
+func (m *Migrator) apply(migration *Migration) error {
+	if err := m.createTable(); err != nil {
+		return err
+	}
+	m.orm.log.Logger.Infof("applying migration [%s]", migration.ID)
+	err := m.transaction(func(tx *gorm.DB) error {
+		if err := run(tx, migration.Up, migration.UpSQL); err != nil {
+			return err
+		}
+		return tx.Create(&SchemaMigration{
+			ID:        migration.ID,
+			Checksum:  migration.Checksum(),
+			AppliedAt: time.Now().UTC(),
+		}).Error
+	})
+	if err != nil {
+		return errors.Wrapf(err, "migration [%s] failed", migration.ID)
+	}
+	return nil
+}
+
+// rollback rolls back an applied migration and deletes its record in a single
+// transaction.
+func (m *Migrator) rollback(id string) error {
+	migration := m.findMigration(id)
+	if migration == nil {
+		return errors.Errorf("applied migration [%s] is not registered and cannot be rolled back", id)
+	}
+	if !migration.canRollback() {
+		return errors.Errorf("migration [%s] cannot be rolled back", id)
+	}
+	m.orm.log.Logger.Infof("rolling back migration [%s]", id)
+	err := m.transaction(func(tx *gorm.DB) error {
+		if err := run(tx, migration.Down, migration.DownSQL); err != nil {
+			return err
+		}
+		return tx.Delete(&SchemaMigration{ID: id}).Error
+	})
+	if err != nil {
+		return errors.Wrapf(err, "rollback of migration [%s] failed", id)
+	}
+	return nil
+}
+
+// transaction runs fn in a transaction.
+func (m *Migrator) transaction(fn func(tx *gorm.DB) error) error {
+	return m.getDB().Transaction(fn)
+}
+
+func run(tx *gorm.DB, fn func(tx *gorm.DB) error, statements string) error {
+	if fn != nil {
+		return fn(tx)
+	}
+	return tx.Exec(statements).Error
+}
+
+// applied returns the records of the applied migrations by ID, none if the
+// schema_migrations table does not exist yet.
+func (m *Migrator) applied() (map[string]SchemaMigration, error) {
+	db := m.getDB()
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return map[string]SchemaMigration{}, nil
+	}
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to read the applied migrations")
+	}
+	res := make(map[string]SchemaMigration, len(records))
+	for _, record := range records {
+		res[record.ID] = record
+	}
+	return res, nil
+}
+
+// createTable creates the schema_migrations table if it does not exist.
+func (m *Migrator) createTable() error {
+	if err := m.getDB().AutoMigrate(&SchemaMigration{}); err != nil {
+		return errors.Wrapf(err, "failed to create the %s table", SchemaMigrationsTable)
+	}
+	return nil
+}
+
+// sortedIDs returns the sorted IDs of the applied migrations.
+func sortedIDs(applied map[string]SchemaMigration) []string {
+	res := make([]string, 0, len(applied))
+	for id := range applied {
+		res = append(res, id)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// checkModified returns an error if an applied migration was modified.
+func (m *Migrator) checkModified(applied map[string]SchemaMigration) error {
+	for _, migration := range m.migrations {
+		if record, ok := applied[migration.ID]; ok && record.Checksum != migration.Checksum() {
+			return errors.Errorf("migration [%s] was modified after it was applied", migration.ID)
+		}
+	}
+	return nil
+}
+
+// getDB returns the DB used to migrate.
+func (m *Migrator) getDB() *gorm.DB {
+	if m.db != nil {
+		return m.db
+	}
+	return m.orm.GetPrimaryDB()
+}
+
+func (m *Migrator) findMigration(id string) *Migration {
+	for _, migration := range m.migrations {
+		if migration.ID == id {
+			return migration
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// recordingConnPool is a connection pool recording the statements executed
+// through it instead of executing them. Read queries are executed so that the
+// current schema of the database can be inspected.
+type recordingConnPool struct {
+	gorm.ConnPool
+	dialector  gorm.Dialector
+	statements *[]string
+}
+
+// ExecContext records the statement.
+func (p *recordingConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	p.record(query, args)
+	return driver.RowsAffected(0), nil
+}
+
+// QueryContext executes the read queries and records the other statements
+// (e.g. INSERT ... RETURNING), which return no rows.
+func (p *recordingConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if isReadQuery(query) {
+		return p.ConnPool.QueryContext(ctx, query, args...)
+	}
+	p.record(query, args)
+	return p.ConnPool.QueryContext(ctx, emptyQuery)
+}
+
+// QueryRowContext executes the read queries and records the other
+// statements, whose row is not found.
+func (p *recordingConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if isReadQuery(query) {
+		return p.ConnPool.QueryRowContext(ctx, query, args...)
+	}
+	p.record(query, args)
+	return p.ConnPool.QueryRowContext(ctx, emptyQuery)
+}
+
+// BeginTx returns a transaction recording its statements in the same list,
+// the transaction itself being neither started nor committed.
+func (p *recordingConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	return &recordingTx{recordingConnPool: p}, nil
+}
+
+func (p *recordingConnPool) record(query string, args []interface{}) {
+	*p.statements = append(*p.statements, p.dialector.Explain(query, args...))
+}
+
+// recordingTx is a transaction of a recordingConnPool. Its savepoints are
+// recorded as statements.
+type recordingTx struct {
+	*recordingConnPool
+}
+
+func (tx *recordingTx) Commit() error {
+	return nil
+}
+
+func (tx *recordingTx) Rollback() error {
+	return nil
+}
+
+// emptyQuery is the query executed in place of the recorded statements
+// returning rows.
+const emptyQuery = "SELECT 1 WHERE 1 = 0"
+
+// isReadQuery returns whether the query only reads the database.
+func isReadQuery(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "SHOW":
+		return true
+	}
+	return false
+}
+
+// DryRun returns the statements that Initialize would execute, generated by
+// the gorm AutoMigrate of the models and by the pending versioned migrations,
+// without executing them nor taking the migration lock. The SELECT and SHOW
+// queries are executed on the primary, so that the current schema can be
+// inspected, the other statements being recorded.
+// A dry run cannot capture:
+//	- the statements of the migration functions executed through another
+//	  connection than the given transaction,
+//	- the effects of the recorded statements on the following queries, e.g.
+//	  the rows inserted by a migration are not read by the next ones and the
+//	  statements returning rows (INSERT ... RETURNING) return none,
+//	- the writes of read queries, e.g. SELECT nextval(...) or functions with
+//	  side effects, which are executed.
+// The transactions are not recorded, their savepoints are.
+func (m *Migrator) DryRun() ([]string, error) {
+	db := m.orm.GetPrimaryDB()
+	recorder := &recordingConnPool{ConnPool: db.Statement.ConnPool, dialector: db.Dialector, statements: &[]string{}}
+	session := db.Session(&gorm.Session{SkipDefaultTransaction: true, Context: context.Background()})
+	session.Statement.ConnPool = recorder
+
+	dryRun := &Migrator{
+		orm:        m.orm,
+		models:     m.models,
+		migrations: m.migrations,
+		db:         session,
+	}
+	if err := dryRun.migrate(); err != nil {
+		return nil, errors.Wrap(err, "dry run failed")
+	}
+	return *recorder.statements, nil
+}
+
+// WriteDryRun writes the statements returned by DryRun to w, each one followed
+// by a semicolon.
+func (m *Migrator) WriteDryRun(w io.Writer) error {
+	statements, err := m.DryRun()
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		statement = strings.TrimRight(strings.TrimSpace(statement), ";")
+		if _, err := fmt.Fprintf(w, "%s;\n", statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SchemaDiff lists the differences between a model and the table of the
+// database.
+type SchemaDiff struct {
+	Table string
+	// MissingTable is true if the table does not exist.
+	MissingTable bool
+	// MissingColumns are the columns of the model missing in the table.
+	MissingColumns []string
+	// ExtraColumns are the columns of the table which are not part of the
+	// model, AutoMigrate never dropping columns.
+	ExtraColumns []string
+	// MissingIndexes are the indexes of the model missing in the table.
+	MissingIndexes []string
+}
+
+// String returns a report of the differences, one per line.
+func (d SchemaDiff) String() string {
+	if d.MissingTable {
+		return fmt.Sprintf("table %s: missing", d.Table)
+	}
+	var lines []string
+	for _, column := range d.MissingColumns {
+		lines = append(lines, fmt.Sprintf("table %s: missing column %s", d.Table, column))
+	}
+	for _, column := range d.ExtraColumns {
+		lines = append(lines, fmt.Sprintf("table %s: extra column %s", d.Table, column))
+	}
+	for _, index := range d.MissingIndexes {
+		lines = append(lines, fmt.Sprintf("table %s: missing index %s", d.Table, index))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (d SchemaDiff) isEmpty() bool {
+	return !d.MissingTable && len(d.MissingColumns) == 0 && len(d.ExtraColumns) == 0 && len(d.MissingIndexes) == 0
+}
+
+// Diff compares the models handled by the Migrator with the current schema of
+// the database and returns the differences of the tables which differ.
+func (m *Migrator) Diff() ([]SchemaDiff, error) {
+	db := m.orm.GetPrimaryDB()
+	migrator := db.Migrator()
+	var res []SchemaDiff
+	for _, model := range m.models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse model [%T]", model)
+		}
+		diff := SchemaDiff{Table: stmt.Schema.Table}
+		if !migrator.HasTable(model) {
+			diff.MissingTable = true
+			res = append(res, diff)
+			continue
+		}
+
+		columnTypes, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the columns of table [%s]", diff.Table)
+		}
+		columns := map[string]bool{}
+		for _, columnType := range columnTypes {
+			columns[strings.ToLower(columnType.Name())] = true
+		}
+		modelColumns := map[string]bool{}
+		for _, dbName := range stmt.Schema.DBNames {
+			modelColumns[strings.ToLower(dbName)] = true
+			if !columns[strings.ToLower(dbName)] {
+				diff.MissingColumns = append(diff.MissingColumns, dbName)
+			}
+		}
+		for _, columnType := range columnTypes {
+			if !modelColumns[strings.ToLower(columnType.Name())] {
+				diff.ExtraColumns = append(diff.ExtraColumns, columnType.Name())
+			}
+		}
+		for name := range stmt.Schema.ParseIndexes() {
+			if !migrator.HasIndex(model, name) {
+				diff.MissingIndexes = append(diff.MissingIndexes, name)
+			}
+		}
+		sort.Strings(diff.MissingIndexes)
+
+		if !diff.isEmpty() {
+			res = append(res, diff)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,193 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SchemaMigrationsLockTable is the name of the table used to lock the
+// migrations on databases without advisory locks (sqlite).
+const SchemaMigrationsLockTable = "schema_migrations_lock"
+
+// lockPollInterval is the interval between two attempts to take the
+// migration lock.
+const lockPollInterval = 100 * time.Millisecond
+
+// SchemaMigrationLock is the record of the schema_migrations_lock table held
+// by the instance running the migrations.
+type SchemaMigrationLock struct {
+	ID       int `gorm:"primaryKey;autoIncrement:false"`
+	LockedBy string
+	LockedAt time.Time
+}
+
+// TableName returns SchemaMigrationsLockTable.
+func (SchemaMigrationLock) TableName() string {
+	return SchemaMigrationsLockTable
+}
+
+// migrationLockID is the key of the postgres advisory lock.
+var migrationLockID = func() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("server-common-go:" + SchemaMigrationsTable))
+	return int64(h.Sum64())
+}()
+
+// withLock runs fn while holding the migration lock so that a single instance
+// migrates the database at a time. The lock is a postgres advisory lock, or a
+// record of the schema_migrations_lock table for the other databases. The
+// lock is awaited at most for the MigrationLockTimeout of the orm
+// configuration (indefinitely if zero), and released even if fn panics.
+func (m *Migrator) withLock(fn func() error) (err error) {
+	ctx := context.Background()
+	if timeout := m.orm.getConfig().MigrationLockTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var release func() error
+	if m.orm.GetPrimaryDB().Dialector.Name() == "postgres" {
+		release, err = m.lockAdvisory(ctx)
+	} else {
+		release, err = m.lockTable(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if releaseErr := release(); releaseErr != nil {
+			m.orm.log.Logger.Errorf("failed to release the migration lock: %v", releaseErr)
+			if err == nil {
+				err = releaseErr
+			}
+		}
+	}()
+
+	return fn()
+}
+
+// lockAdvisory takes the postgres advisory lock on a dedicated connection, the
+// lock being held by the session.
+func (m *Migrator) lockAdvisory(ctx context.Context) (func() error, error) {
+	conn, err := m.orm.sqldb.Conn(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get a connection for the migration lock")
+	}
+	err = poll(ctx, func() (bool, error) {
+		var locked bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockID).Scan(&locked)
+		return locked, err
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+		return err
+	}, nil
+}
+
+// lockTable takes the lock by inserting the single record of the
+// schema_migrations_lock table. The record is refreshed while the lock is
+// held, so that the record of an instance which stopped while migrating is
+// removed once it is older than the MigrationLockExpiration of the orm
+// configuration.
+func (m *Migrator) lockTable(ctx context.Context) (func() error, error) {
+	db := m.orm.GetPrimaryDB()
+	if err := db.AutoMigrate(&SchemaMigrationLock{}); err != nil {
+		return nil, errors.Wrapf(err, "failed to create the %s table", SchemaMigrationsLockTable)
+	}
+	expiration := m.orm.getConfig().MigrationLockExpiration
+	hostname, _ := os.Hostname()
+	lock := &SchemaMigrationLock{
+		ID:       1,
+		LockedBy: fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+	}
+	err := poll(ctx, func() (bool, error) {
+		lock.LockedAt = time.Now().UTC()
+		err := db.Create(lock).Error
+		if err == nil {
+			return true, nil
+		}
+		// the insertion fails while another instance holds the lock.
+		if ErrorCategoryOf(err) != UniqueViolation {
+			return false, err
+		}
+		if expiration > 0 {
+			return false, m.removeStaleLock(expiration)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		if expiration <= 0 {
+			return
+		}
+		ticker := time.NewTicker(expiration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := db.Model(&SchemaMigrationLock{ID: 1}).Where("locked_by = ?", lock.LockedBy).Update("locked_at", time.Now().UTC()).Error
+				if err != nil {
+					m.orm.log.Logger.Warnf("failed to refresh the migration lock: %v", err)
+				}
+			}
+		}
+	}()
+	return func() error {
+		close(stop)
+		<-done
+		return db.Where("locked_by = ?", lock.LockedBy).Delete(&SchemaMigrationLock{ID: 1}).Error
+	}, nil
+}
+
+// removeStaleLock removes the lock record if it was not refreshed for the
+// given expiration.
+func (m *Migrator) removeStaleLock(expiration time.Duration) error {
+	result := m.orm.GetPrimaryDB().
+		Where("locked_at < ?", time.Now().UTC().Add(-expiration)).
+		Delete(&SchemaMigrationLock{ID: 1})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		m.orm.log.Logger.Warnf("removed the stale migration lock, not refreshed for %s", expiration)
+	}
+	return nil
+}
+
+// poll calls tryLock until it succeeds or the context is done.
+func poll(ctx context.Context, tryLock func() (bool, error)) error {
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+	for {
+		locked, err := tryLock()
+		if err != nil {
+			return errors.Wrap(err, "failed to take the migration lock")
+		}
+		if locked {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.New("timeout while waiting for the migration lock held by another instance")
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package orm
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	upSQLSuffix   = ".up.sql"
+	downSQLSuffix = ".down.sql"
+)
+
+// LoadSQLMigrations returns the migrations made of the SQL files of the given
+// directory of fsys, typically an embed.FS. The statements applying the
+// migration with the ID <id> are read from <id>.up.sql and the statements
+// rolling it back from the optional <id>.down.sql.
+// ex.)
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//	...
+//	sqlMigrations, err := orm.LoadSQLMigrations(migrations, "migrations")
+func LoadSQLMigrations(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read migration directory [%s]", dir)
+	}
+	migrations := map[string]*Migration{}
+	get := func(id string) *Migration {
+		if migrations[id] == nil {
+			migrations[id] = &Migration{ID: id}
+		}
+		return migrations[id]
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read migration file [%s]", name)
+		}
+		switch {
+		case strings.HasSuffix(name, upSQLSuffix):
+			get(strings.TrimSuffix(name, upSQLSuffix)).UpSQL = string(content)
+		case strings.HasSuffix(name, downSQLSuffix):
+			get(strings.TrimSuffix(name, downSQLSuffix)).DownSQL = string(content)
+		default:
+			return nil, errors.Errorf("invalid migration file name [%s], expecting <id>%s or <id>%s", name, upSQLSuffix, downSQLSuffix)
+		}
+	}
+
+	res := make([]*Migration, 0, len(migrations))
+	for id, migration := range migrations {
+		if strings.TrimSpace(migration.UpSQL) == "" {
+			return nil, errors.Errorf("migration [%s] has no %s file or it is empty", id, upSQLSuffix)
+		}
+		res = append(res, migration)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
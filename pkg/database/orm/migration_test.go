@@ -1,9 +1,15 @@
 package orm_test
 
 import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
 	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
 	"github.com/cryptogarageinc/server-common-go/test"
-	"testing"
+	"gorm.io/gorm"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -45,3 +51,470 @@ func TestMigrationInitialize_HasCorrectTable(t *testing.T) {
 	// Assert
 	assert.NoError(err)
 }
+
+type TestVersionedModel struct {
+	Name  string
+	Email string
+}
+
+type TestVersionedItem struct {
+	Label string
+}
+
+func newTestMigrator(migrations ...*orm.Migration) (*orm.ORM, *orm.Migrator) {
+	ormConfig := &orm.Config{}
+	test.InitializeConfig(ormConfig)
+	ormInstance := orm.NewORM(ormConfig, test.NewLogger())
+	ormInstance.Initialize()
+	migrator := orm.NewMigrator(ormInstance)
+	migrator.Register(migrations...)
+	return ormInstance, migrator
+}
+
+func newTestMigrations() []*orm.Migration {
+	return []*orm.Migration{
+		{
+			ID:      "0001_create_models",
+			UpSQL:   "CREATE TABLE test_versioned_models (name TEXT)",
+			DownSQL: "DROP TABLE test_versioned_models",
+		},
+		{
+			ID: "0002_create_items",
+			Up: func(tx *gorm.DB) error {
+				return tx.Migrator().CreateTable(&TestVersionedItem{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&TestVersionedItem{})
+			},
+		},
+	}
+}
+
+func TestMigratorUp_WithPendingMigrations_AppliesMigrations(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, migrator := newTestMigrator(newTestMigrations()...)
+
+	// Act
+	err := migrator.Up()
+	status, statusErr := migrator.Status()
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(statusErr)
+	assert.True(ormInstance.GetDB().Migrator().HasTable(&TestVersionedItem{}))
+	assert.Len(status, 2)
+	assert.True(status[0].Applied)
+	assert.True(status[1].Applied)
+	assert.False(status[0].Modified)
+}
+
+func TestMigratorDown_WithOneStep_RollsBackLastMigration(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, migrator := newTestMigrator(newTestMigrations()...)
+	migrator.Up()
+
+	// Act
+	err := migrator.Down(1)
+	status, _ := migrator.Status()
+
+	// Assert
+	assert.NoError(err)
+	assert.True(ormInstance.GetDB().Migrator().HasTable(&TestVersionedModel{}))
+	assert.False(ormInstance.GetDB().Migrator().HasTable(&TestVersionedItem{}))
+	assert.True(status[0].Applied)
+	assert.False(status[1].Applied)
+}
+
+func TestMigratorTo_WithVersion_AppliesAndRollsBackMigrations(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, migrator := newTestMigrator(newTestMigrations()...)
+
+	// Act
+	upErr := migrator.To("0001_create_models")
+	hasTable := ormInstance.GetDB().Migrator().HasTable(&TestVersionedModel{})
+	hasItems := ormInstance.GetDB().Migrator().HasTable(&TestVersionedItem{})
+	downErr := migrator.To("")
+	unknownErr := migrator.To("0003_unknown")
+
+	// Assert
+	assert.NoError(upErr)
+	assert.True(hasTable)
+	assert.False(hasItems)
+	assert.NoError(downErr)
+	assert.False(ormInstance.GetDB().Migrator().HasTable(&TestVersionedModel{}))
+	assert.Error(unknownErr)
+}
+
+func TestMigratorUp_WithFailingMigration_RollsBackTransaction(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, migrator := newTestMigrator(&orm.Migration{
+		ID: "0001_failing",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("CREATE TABLE test_versioned_models (name TEXT)").Error; err != nil {
+				return err
+			}
+			return errors.New("backfill failed")
+		},
+	})
+
+	// Act
+	err := migrator.Up()
+	status, _ := migrator.Status()
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "0001_failing")
+	assert.False(ormInstance.GetDB().Migrator().HasTable(&TestVersionedModel{}))
+	assert.False(status[0].Applied)
+}
+
+func TestMigratorUp_WithModifiedMigration_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	migrations := newTestMigrations()
+	ormInstance, migrator := newTestMigrator(migrations...)
+	migrator.Up()
+	migrations[0].UpSQL = "CREATE TABLE test_versioned_models (name VARCHAR(10))"
+	modifiedMigrator := orm.NewMigrator(ormInstance)
+	modifiedMigrator.Register(migrations...)
+
+	// Act
+	err := modifiedMigrator.Up()
+	status, _ := modifiedMigrator.Status()
+
+	// Assert
+	assert.Error(err)
+	assert.True(status[0].Modified)
+}
+
+func TestMigratorStatus_WithUnregisteredMigration_ReturnsMissing(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, migrator := newTestMigrator(newTestMigrations()...)
+	migrator.Up()
+	otherMigrator := orm.NewMigrator(ormInstance)
+	otherMigrator.Register(newTestMigrations()[0])
+
+	// Act
+	status, err := otherMigrator.Status()
+	downErr := otherMigrator.Down(1)
+
+	// Assert
+	assert.NoError(err)
+	assert.Len(status, 2)
+	assert.True(status[1].Missing)
+	assert.Error(downErr)
+}
+
+func TestMigratorDown_WithModifiedMigration_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	migrations := newTestMigrations()
+	ormInstance, migrator := newTestMigrator(migrations...)
+	migrator.Up()
+	migrations[0].DownSQL = "DELETE FROM test_versioned_models"
+	modifiedMigrator := orm.NewMigrator(ormInstance)
+	modifiedMigrator.Register(migrations...)
+
+	// Act
+	err := modifiedMigrator.Down(1)
+
+	// Assert
+	assert.Error(err)
+	assert.True(ormInstance.GetDB().Migrator().HasTable(&TestVersionedItem{}))
+}
+
+func TestMigratorStatus_WithoutMigrationsTable_DoesNotCreateTable(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, migrator := newTestMigrator(newTestMigrations()...)
+
+	// Act
+	status, err := migrator.Status()
+
+	// Assert
+	assert.NoError(err)
+	assert.Len(status, 2)
+	assert.False(status[0].Applied)
+	assert.False(ormInstance.GetDB().Migrator().HasTable(&orm.SchemaMigration{}))
+}
+
+func TestMigratorRegister_WithDuplicateID_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, migrator := newTestMigrator()
+	migrations := newTestMigrations()
+
+	// Act
+	err := migrator.Register(migrations[0], migrations[0])
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestLoadSQLMigrations_WithSQLFiles_ReturnsMigrations(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	fsys := fstest.MapFS{
+		"migrations/0002_add_email.up.sql":       {Data: []byte("ALTER TABLE test_versioned_models ADD COLUMN email TEXT")},
+		"migrations/0001_create_models.up.sql":   {Data: []byte("CREATE TABLE test_versioned_models (name TEXT)")},
+		"migrations/0001_create_models.down.sql": {Data: []byte("DROP TABLE test_versioned_models")},
+	}
+
+	// Act
+	migrations, err := orm.LoadSQLMigrations(fsys, "migrations")
+	ormInstance, migrator := newTestMigrator(migrations...)
+	upErr := migrator.Up()
+
+	// Assert
+	assert.NoError(err)
+	assert.Len(migrations, 2)
+	assert.Equal("0001_create_models", migrations[0].ID)
+	assert.Equal("DROP TABLE test_versioned_models", migrations[0].DownSQL)
+	assert.Empty(migrations[1].DownSQL)
+	assert.NoError(upErr)
+	assert.True(ormInstance.GetDB().Migrator().HasColumn(&TestVersionedModel{}, "Email"))
+}
+
+func TestLoadSQLMigrations_WithoutUpFile_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	fsys := fstest.MapFS{
+		"migrations/0001_create_models.down.sql": {Data: []byte("DROP TABLE test_versioned_models")},
+	}
+
+	// Act
+	_, err := orm.LoadSQLMigrations(fsys, "migrations")
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestMigratorUp_WithLockHeld_ReturnsTimeoutError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormConfig := &orm.Config{}
+	test.InitializeConfig(ormConfig)
+	ormConfig.MigrationLockTimeout = 300 * time.Millisecond
+	ormInstance := orm.NewORM(ormConfig, test.NewLogger())
+	ormInstance.Initialize()
+	migrator := orm.NewMigrator(ormInstance)
+	migrator.Register(newTestMigrations()...)
+	db := ormInstance.GetDB()
+	db.AutoMigrate(&orm.SchemaMigrationLock{})
+	db.Create(&orm.SchemaMigrationLock{ID: 1, LockedBy: "other", LockedAt: time.Now().UTC()})
+
+	// Act
+	lockedErr := migrator.Up()
+	db.Delete(&orm.SchemaMigrationLock{ID: 1})
+	err := migrator.Up()
+
+	// Assert
+	assert.Error(lockedErr)
+	assert.Contains(lockedErr.Error(), "timeout")
+	assert.NoError(err)
+	var count int64
+	db.Model(&orm.SchemaMigrationLock{}).Count(&count)
+	assert.Equal(int64(0), count)
+}
+
+func TestMigratorUp_WithStaleLock_RemovesLock(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormConfig := &orm.Config{}
+	test.InitializeConfig(ormConfig)
+	ormConfig.MigrationLockTimeout = time.Second
+	ormConfig.MigrationLockExpiration = time.Minute
+	ormInstance := orm.NewORM(ormConfig, test.NewLogger())
+	ormInstance.Initialize()
+	migrator := orm.NewMigrator(ormInstance)
+	migrator.Register(newTestMigrations()...)
+	db := ormInstance.GetDB()
+	db.AutoMigrate(&orm.SchemaMigrationLock{})
+	db.Create(&orm.SchemaMigrationLock{ID: 1, LockedBy: "stopped", LockedAt: time.Now().UTC().Add(-time.Hour)})
+
+	// Act
+	err := migrator.Up()
+
+	// Assert
+	assert.NoError(err)
+	assert.True(db.Migrator().HasTable(&TestVersionedItem{}))
+	var count int64
+	db.Model(&orm.SchemaMigrationLock{}).Count(&count)
+	assert.Equal(int64(0), count)
+}
+
+func TestMigratorUp_WithPanickingMigration_ReleasesLock(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, migrator := newTestMigrator(&orm.Migration{
+		ID: "0001_panic",
+		Up: func(tx *gorm.DB) error {
+			panic("migration failed")
+		},
+	})
+
+	// Act
+	act := func() { migrator.Up() }
+
+	// Assert
+	assert.Panics(act)
+	var count int64
+	ormInstance.GetDB().Model(&orm.SchemaMigrationLock{}).Count(&count)
+	assert.Equal(int64(0), count)
+}
+
+type TestIndexedModel struct {
+	Name string `gorm:"index"`
+	Code string
+}
+
+func TestMigratorDryRun_WithModelsAndMigrations_ReturnsStatementsWithoutExecuting(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, _ := newTestMigrator()
+	migrator := orm.NewMigrator(ormInstance, &TestIndexedModel{})
+	migrator.Register(newTestMigrations()...)
+	var b strings.Builder
+
+	// Act
+	statements, err := migrator.DryRun()
+	writeErr := migrator.WriteDryRun(&b)
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(writeErr)
+	sql := strings.Join(statements, "\n")
+	assert.Contains(sql, "CREATE TABLE `test_indexed_models`")
+	assert.Contains(sql, "CREATE INDEX `idx_test_indexed_models_name`")
+	assert.Contains(sql, "CREATE TABLE test_versioned_models (name TEXT)")
+	assert.Contains(sql, "CREATE TABLE `test_versioned_items`")
+	assert.Contains(sql, "INSERT INTO `schema_migrations`")
+	assert.Contains(sql, "0002_create_items")
+	assert.Contains(b.String(), "CREATE TABLE test_versioned_models (name TEXT);\n")
+	db := ormInstance.GetDB()
+	assert.False(db.Migrator().HasTable(&TestIndexedModel{}))
+	assert.False(db.Migrator().HasTable(&TestVersionedItem{}))
+	assert.False(db.Migrator().HasTable(&orm.SchemaMigration{}))
+}
+
+func TestMigratorDryRun_WithAppliedMigrations_ReturnsPendingStatements(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	migrations := newTestMigrations()
+	ormInstance, migrator := newTestMigrator(migrations[0])
+	migrator.Up()
+	pendingMigrator := orm.NewMigrator(ormInstance)
+	pendingMigrator.Register(migrations...)
+
+	// Act
+	statements, err := pendingMigrator.DryRun()
+
+	// Assert
+	assert.NoError(err)
+	sql := strings.Join(statements, "\n")
+	assert.NotContains(sql, "CREATE TABLE test_versioned_models")
+	assert.Contains(sql, "CREATE TABLE `test_versioned_items`")
+}
+
+func TestMigratorDryRun_WithTransactionsAndQueries_RecordsWrites(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, _ := newTestMigrator()
+	db := ormInstance.GetDB()
+	db.AutoMigrate(&TestVersionedModel{})
+	migrator := orm.NewMigrator(ormInstance)
+	migrator.Register(&orm.Migration{
+		ID: "0001_backfill",
+		Up: func(tx *gorm.DB) error {
+			return tx.Transaction(func(tx *gorm.DB) error {
+				var names []string
+				if err := tx.Raw("INSERT INTO test_versioned_models (name) VALUES (?) RETURNING name", "inserted").Scan(&names).Error; err != nil {
+					return err
+				}
+				return tx.Raw("SELECT name FROM test_versioned_models").Scan(&names).Error
+			})
+		},
+	})
+
+	// Act
+	statements, err := migrator.DryRun()
+
+	// Assert
+	assert.NoError(err)
+	sql := strings.Join(statements, "\n")
+	assert.Contains(sql, "SAVEPOINT")
+	assert.Contains(sql, "INSERT INTO test_versioned_models (name) VALUES (\"inserted\") RETURNING name")
+	assert.NotContains(sql, "SELECT name FROM test_versioned_models")
+	var count int64
+	db.Model(&TestVersionedModel{}).Count(&count)
+	assert.Equal(int64(0), count)
+}
+
+func TestMigratorDryRun_WithReplicas_InspectsPrimary(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := newReplicatedOrm(t, orm.RoundRobinReplicaPolicy)
+	migrator := orm.NewMigrator(ormInstance, &TestModel{})
+
+	// Act
+	statements, err := migrator.DryRun()
+
+	// Assert
+	assert.NoError(err)
+	assert.NotContains(strings.Join(statements, "\n"), "CREATE TABLE")
+}
+
+func TestMigratorDiff_WithOutdatedSchema_ReturnsDifferences(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, _ := newTestMigrator()
+	db := ormInstance.GetDB()
+	db.Exec("CREATE TABLE test_indexed_models (name TEXT, legacy TEXT)")
+	migrator := orm.NewMigrator(ormInstance, &TestIndexedModel{}, &TestVersionedItem{}, &TestVersionedModel{})
+	db.AutoMigrate(&TestVersionedModel{})
+
+	// Act
+	diffs, err := migrator.Diff()
+
+	// Assert
+	assert.NoError(err)
+	assert.Len(diffs, 2)
+	assert.Equal(orm.SchemaDiff{
+		Table:          "test_indexed_models",
+		MissingColumns: []string{"code"},
+		ExtraColumns:   []string{"legacy"},
+		MissingIndexes: []string{"idx_test_indexed_models_name"},
+	}, diffs[0])
+	assert.Equal("table test_indexed_models: missing column code\n"+
+		"table test_indexed_models: extra column legacy\n"+
+		"table test_indexed_models: missing index idx_test_indexed_models_name", diffs[0].String())
+	assert.True(diffs[1].MissingTable)
+	assert.Equal("table test_versioned_items: missing", diffs[1].String())
+}
+
+func TestMigratorForce_WithVersion_RecordsMigrationsWithoutApplying(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	migrations := newTestMigrations()
+	ormInstance, migrator := newTestMigrator(migrations...)
+	migrator.Up()
+	migrations[0].UpSQL = "CREATE TABLE test_versioned_models (name VARCHAR(10))"
+
+	// Act
+	err := migrator.Force("0001_create_models")
+	status, _ := migrator.Status()
+	upErr := migrator.Up()
+
+	// Assert
+	assert.NoError(err)
+	assert.True(status[0].Applied)
+	assert.False(status[0].Modified)
+	assert.False(status[1].Applied)
+	assert.True(ormInstance.GetDB().Migrator().HasTable(&TestVersionedItem{}))
+	assert.Error(upErr)
+}
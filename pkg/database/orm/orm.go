@@ -1,14 +1,18 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sync"
+	"time"
 
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 
 	"reflect"
 
+	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
 	"github.com/cryptogarageinc/server-common-go/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -19,6 +23,8 @@ import (
 
 // ORM represent an Object Relational Mapper instance.
 type ORM struct {
+	// configMu guards config, replaced when the configuration is reloaded.
+	configMu      sync.RWMutex
 	config        *Config
 	log           *log.Log
 	connectionStr string
@@ -26,9 +32,21 @@ type ORM struct {
 	logger        *logrus.Logger
 	initialized   bool
 	db            *gorm.DB
+	primaryDB     *gorm.DB
 	sqldb         *sql.DB
+	replicas      *replicaSet
+
+	// statsMu guards the pool statistics reporting.
+	statsMu   sync.Mutex
+	statsHook StatsHook
+	statsStop chan struct{}
+	statsDone chan struct{}
 }
 
+// StatsHook receives the statistics of the connection pool, e.g. to export
+// them as metrics.
+type StatsHook func(stats sql.DBStats)
+
 // NewORM creates a new ORM structure with the given parameters.
 func NewORM(config *Config, l *log.Log) *ORM {
 	return &ORM{
@@ -48,29 +66,17 @@ func (o *ORM) Initialize() error {
 	o.log.Logger.Info("ORM initialization starts")
 	defer o.log.Logger.Info("ORM initialization end")
 
-	enableLog := o.config.EnableLogging
+	config := o.getConfig()
+	enableLog := config.EnableLogging
 
 	o.enableLog = enableLog
 	o.logger = o.log.Logger
 
-	var dbDialector gorm.Dialector
-
-	if o.config.InMemory {
+	if config.InMemory {
 		o.log.Logger.Info("InMemory flag detected : Using Sqlite Inmemory DB")
-		o.connectionStr = ":memory:"
-		dbDialector = sqlite.Open(o.connectionStr)
-	} else {
-		// postgres db
-		o.connectionStr = fmt.Sprintf(
-			"host=%s port=%s dbname=%s user=%s password=%s %s",
-			o.config.Host,
-			o.config.Port,
-			o.config.DbName,
-			o.config.DbUser,
-			o.config.DbPassword,
-			o.config.ConnectionParams)
-		dbDialector = postgres.Open(o.connectionStr)
 	}
+	o.connectionStr = o.connectionString(config.Host, config.Port)
+	dbDialector := o.dialector(o.connectionStr)
 
 	var newLogger logger.Interface
 	var level logger.LogLevel
@@ -94,9 +100,10 @@ func (o *ORM) Initialize() error {
 		LogLevel: level,
 	})
 
-	opened, err := gorm.Open(dbDialector, &gorm.Config{
+	gormConfig := &gorm.Config{
 		Logger: newLogger,
-	})
+	}
+	opened, err := gorm.Open(dbDialector, gormConfig)
 
 	if err != nil {
 		o.log.Logger.Error(err, "Could not open database.")
@@ -111,12 +118,66 @@ func (o *ORM) Initialize() error {
 		return err
 	}
 	o.sqldb = sqldb
-	o.sqldb.SetConnMaxLifetime(o.config.ConnectionLifetime)
+	if err := o.initializeReplicas(gormConfig); err != nil {
+		o.log.Logger.Error(err)
+		return err
+	}
+	o.primaryDB = o.db
+	if o.replicas != nil {
+		o.primaryDB = o.db.Session(&gorm.Session{Context: context.Background()})
+		o.primaryDB.Statement.ConnPool = &primaryConnPool{DB: sqldb}
+	}
+	o.applyPoolSettings(config)
 	o.initialized = true
+	o.startStatsReporting(config.StatsInterval)
 
 	return nil
 }
 
+// connectionString returns the connection string of the database with the
+// given host and port.
+func (o *ORM) connectionString(host, port string) string {
+	config := o.getConfig()
+	if config.InMemory {
+		return ":memory:"
+	}
+	// postgres db
+	return fmt.Sprintf(
+		"host=%s port=%s dbname=%s user=%s password=%s %s",
+		host,
+		port,
+		config.DbName,
+		config.DbUser,
+		config.DbPassword,
+		config.ConnectionParams)
+}
+
+// dialector returns the dialector of the database with the given connection
+// string, an sqlite in memory database in memory mode.
+func (o *ORM) dialector(connectionStr string) gorm.Dialector {
+	if o.getConfig().InMemory {
+		return sqlite.Open(connectionStr)
+	}
+	return postgres.Open(connectionStr)
+}
+
+// applyPoolSettings applies the connection pool settings of the given
+// configuration to the primary and replica pools.
+func (o *ORM) applyPoolSettings(config *Config) {
+	pools := []*sql.DB{o.sqldb}
+	if o.replicas != nil {
+		for _, r := range o.replicas.replicas {
+			pools = append(pools, r.db)
+		}
+	}
+	for _, pool := range pools {
+		pool.SetConnMaxLifetime(config.ConnectionLifetime)
+		pool.SetConnMaxIdleTime(config.ConnectionMaxIdleTime)
+		pool.SetMaxOpenConns(config.MaxOpenConnections)
+		pool.SetMaxIdleConns(config.MaxIdleConnections)
+	}
+}
+
 // IsInitialized returns whether the orm is initialized.
 func (o *ORM) IsInitialized() bool {
 	return o.initialized
@@ -124,6 +185,12 @@ func (o *ORM) IsInitialized() bool {
 
 // Finalize releases the resources held by the orm.
 func (o *ORM) Finalize() error {
+	o.stopStatsReporting()
+	if o.replicas != nil {
+		if err := o.replicas.close(); err != nil {
+			return err
+		}
+	}
 	err := o.sqldb.Close()
 	if err != nil {
 		return errors.Errorf("failed to close database connection")
@@ -131,8 +198,120 @@ func (o *ORM) Finalize() error {
 	return nil
 }
 
+// Subscribe registers the orm to the changes of the given configuration so that
+// the connection pool settings are updated without restart. Changes to the
+// connection parameters (host, credentials...) require a restart.
+// The orm configuration must have been initialized from c.
+func (o *ORM) Subscribe(c *conf.Configuration) error {
+	return c.Subscribe(o.getConfig(), o.onConfigChange)
+}
+
+// getConfig returns the current orm configuration.
+func (o *ORM) getConfig() *Config {
+	o.configMu.RLock()
+	defer o.configMu.RUnlock()
+	return o.config
+}
+
+// onConfigChange applies the pool settings of the new orm configuration.
+func (o *ORM) onConfigChange(oldConf, newConf interface{}) {
+	config := newConf.(*Config)
+	o.configMu.Lock()
+	o.config = config
+	o.configMu.Unlock()
+	if !o.initialized {
+		return
+	}
+	o.applyPoolSettings(config)
+	o.startStatsReporting(config.StatsInterval)
+	o.log.Logger.Infof(
+		"database configuration reloaded (connection lifetime: %s, max open connections: %d, max idle connections: %d, connection max idle time: %s)",
+		config.ConnectionLifetime, config.MaxOpenConnections, config.MaxIdleConnections, config.ConnectionMaxIdleTime)
+}
+
+// Stats returns the statistics of the connection pool. Panics if the object is
+// not initialized.
+func (o *ORM) Stats() sql.DBStats {
+	if !o.IsInitialized() {
+		panic("Trying to access uninitialized ORM object.")
+	}
+	return o.sqldb.Stats()
+}
+
+// SetStatsHook sets a hook receiving the statistics of the connection pool at
+// each StatsInterval of the orm configuration, in addition to their logging.
+func (o *ORM) SetStatsHook(hook StatsHook) {
+	o.statsMu.Lock()
+	defer o.statsMu.Unlock()
+	o.statsHook = hook
+}
+
+// startStatsReporting (re)starts the periodic reporting of the pool
+// statistics, a zero interval stopping it.
+func (o *ORM) startStatsReporting(interval time.Duration) {
+	o.stopStatsReporting()
+	if interval <= 0 {
+		return
+	}
+	o.statsMu.Lock()
+	defer o.statsMu.Unlock()
+	stop, done := make(chan struct{}), make(chan struct{})
+	o.statsStop, o.statsDone = stop, done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				o.reportStats()
+			}
+		}
+	}()
+}
+
+// stopStatsReporting stops the periodic reporting of the pool statistics.
+func (o *ORM) stopStatsReporting() {
+	o.statsMu.Lock()
+	stop, done := o.statsStop, o.statsDone
+	o.statsStop, o.statsDone = nil, nil
+	o.statsMu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// reportStats logs the pool statistics and passes them to the stats hook.
+func (o *ORM) reportStats() {
+	stats := o.sqldb.Stats()
+	o.log.Logger.WithFields(logrus.Fields{
+		"max_open_connections": stats.MaxOpenConnections,
+		"open_connections":     stats.OpenConnections,
+		"in_use":               stats.InUse,
+		"idle":                 stats.Idle,
+		"wait_count":           stats.WaitCount,
+		"wait_duration":        stats.WaitDuration.String(),
+		"max_idle_closed":      stats.MaxIdleClosed,
+		"max_idle_time_closed": stats.MaxIdleTimeClosed,
+		"max_lifetime_closed":  stats.MaxLifetimeClosed,
+	}).Info("database connection pool statistics")
+
+	o.statsMu.Lock()
+	hook := o.statsHook
+	o.statsMu.Unlock()
+	if hook != nil {
+		hook(stats)
+	}
+}
+
 // GetDB returns the DB instance associated with the orm object. Panics if the
 // object is not initialized.
+// If replicas are configured, the queries are routed to the replicas, except
+// within transactions and for locking reads, the other statements being
+// executed by the primary.
 func (o *ORM) GetDB() *gorm.DB {
 	if !o.IsInitialized() {
 		panic("Trying to access uninitialized ORM object.")
@@ -141,6 +320,17 @@ func (o *ORM) GetDB() *gorm.DB {
 	return o.db
 }
 
+// GetPrimaryDB returns a DB instance executing all its statements on the
+// primary database, e.g. to read data just written. It is the same as GetDB
+// if no replica is configured. Panics if the object is not initialized.
+func (o *ORM) GetPrimaryDB() *gorm.DB {
+	if !o.IsInitialized() {
+		panic("Trying to access uninitialized ORM object.")
+	}
+
+	return o.primaryDB
+}
+
 // GetTableName returns the name of the table for the given model.
 // Assumes that the globalDB is initialized, returns empty string if not
 func (o *ORM) GetTableName(model interface{}) string {
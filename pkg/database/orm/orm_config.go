@@ -4,13 +4,36 @@ import "time"
 
 // Config contains the configuration parameter to set up the orm.
 type Config struct {
-	EnableLogging      bool          `configkey:"database.log"` // Whether to enable logging of the database
-	InMemory           bool          `configkey:"database.inmemory" default:"false"`
-	Host               string        `configkey:"database.host" validate:"required"`
-	Port               string        `configkey:"database.port" validate:"required"`
-	DbName             string        `configkey:"database.dbname" default:"postgres"`
-	DbUser             string        `configkey:"database.dbuser" default:"postgres"`
-	DbPassword         string        `configkey:"database.dbpassword" validate:"required"`
-	ConnectionParams   string        `configkey:"database.connectionParams"` // Postgres sql connection parameters separate by space
-	ConnectionLifetime time.Duration `configkey:"database.connectionLifeTime,duration" default:"1h"`
+	EnableLogging      bool          `configkey:"database.log" desc:"Whether to enable logging of the database"`
+	InMemory           bool          `configkey:"database.inmemory" default:"false" desc:"Whether to use an in memory sqlite database"`
+	Host               string        `configkey:"database.host" validate:"required" desc:"Host of the database" example:"db"`
+	Port               string        `configkey:"database.port" validate:"required" desc:"Port of the database" example:"5432"`
+	DbName             string        `configkey:"database.dbname" default:"postgres" desc:"Name of the database"`
+	DbUser             string        `configkey:"database.dbuser" default:"postgres" desc:"User of the database"`
+	DbPassword         string        `configkey:"database.dbpassword,secret" validate:"required" desc:"Password of the database, can be a secret reference (ex. ${secret:file:///run/secrets/db_password})" example:"${DB_PASSWORD}"`
+	ConnectionParams   string        `configkey:"database.connectionParams" desc:"Postgres sql connection parameters separated by space"`
+	ConnectionLifetime time.Duration `configkey:"database.connectionLifeTime,duration" default:"1h" desc:"Maximum lifetime of the connections"`
+	// Read replicas, in memory mode each replica being an independent in
+	// memory database.
+	Replicas                   []string      `configkey:"database.replicas" desc:"Hosts (host or host:port) of the read replicas to which the queries are routed"`
+	ReplicaPolicy              string        `configkey:"database.replicaPolicy" default:"round_robin" validate:"oneof=round_robin random" desc:"Policy used to choose the replica of a query: round_robin or random"`
+	ReplicaHealthCheckInterval time.Duration `configkey:"database.replicaHealthCheckInterval,duration" default:"10s" desc:"Interval between two health checks of the replicas, unhealthy replicas receiving no query, 0 to disable"`
+	// Connection pool settings.
+	MaxOpenConnections    int           `configkey:"database.maxOpenConnections" default:"0" validate:"min=0" desc:"Maximum number of open connections, 0 for unlimited"`
+	MaxIdleConnections    int           `configkey:"database.maxIdleConnections" default:"2" validate:"min=0" desc:"Maximum number of idle connections"`
+	ConnectionMaxIdleTime time.Duration `configkey:"database.connectionMaxIdleTime,duration" default:"0s" desc:"Maximum time a connection can stay idle, 0 for unlimited"`
+	// StatsInterval is the interval at which the pool statistics are logged
+	// and reported to the stats hook, 0 to disable the reporting.
+	StatsInterval time.Duration `configkey:"database.statsInterval,duration" default:"0s" desc:"Interval at which the connection pool statistics are logged, 0 to disable"`
+	// Retries of the transactions of WithTransaction failing on a
+	// serialization failure or a deadlock, the backoff doubling at each retry.
+	TransactionMaxRetries   int           `configkey:"database.transactionMaxRetries" default:"3" validate:"min=0" desc:"Maximum number of retries of the transactions failing on a serialization failure or a deadlock"`
+	TransactionRetryBackoff time.Duration `configkey:"database.transactionRetryBackoff,duration" default:"50ms" desc:"Delay before the first retry of a transaction, doubled at each retry"`
+	// MigrationLockTimeout is the maximum duration to wait for the migration
+	// lock held by another instance, 0 to wait indefinitely.
+	MigrationLockTimeout time.Duration `configkey:"database.migrationLockTimeout,duration" default:"1m" desc:"Maximum duration to wait for the migration lock held by another instance, 0 to wait indefinitely"`
+	// MigrationLockExpiration is the duration after which the lock table
+	// record of an instance which stopped while migrating is removed, the
+	// record being refreshed by the instance holding it. 0 never expires it.
+	MigrationLockExpiration time.Duration `configkey:"database.migrationLockExpiration,duration" default:"1m" desc:"Duration after which the migration lock (sqlite) of an instance which stopped while migrating is removed, the lock being refreshed while it is held, 0 to never remove it"`
 }
@@ -1,12 +1,21 @@
 package orm_test
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
+	"time"
 
+	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
 	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
 	"github.com/cryptogarageinc/server-common-go/test"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 type TestModel struct {
@@ -91,3 +100,265 @@ func TestOrmGetDB_NotInitialized_Panics(t *testing.T) {
 	// Assert
 	assert.Panics(act)
 }
+
+func TestOrmSubscribe_WithPoolSettingsChanged_AppliesSettings(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ormtest.yaml")
+	content := "database:\n  inmemory: true\n  host: sqlite\n  port: 5432\n  dbpassword: 1234\n"
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	config := conf.NewConfiguration("ormtest", "ormtest", []string{dir})
+	require.NoError(t, config.Initialize())
+	ormConfig := &orm.Config{}
+	require.NoError(t, config.InitializeComponentConfig(ormConfig))
+	l := test.NewLogger()
+	var out bytes.Buffer
+	l.Logger.SetOutput(&out)
+	ormInstance := orm.NewORM(ormConfig, l)
+	reported := make(chan sql.DBStats, 10)
+	ormInstance.SetStatsHook(func(stats sql.DBStats) {
+		select {
+		case reported <- stats:
+		default:
+		}
+	})
+	require.NoError(t, ormInstance.Initialize())
+	defer ormInstance.Finalize()
+	require.NoError(t, ormInstance.Subscribe(config))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content+"  connectionLifeTime: 2h\n  maxOpenConnections: 4\n  statsInterval: 10ms\n"), 0600))
+
+	// Act
+	err := config.Reload()
+	var stats sql.DBStats
+	select {
+	case stats = <-reported:
+	case <-time.After(time.Second):
+	}
+
+	// Assert
+	assert.NoError(err)
+	// the initial configuration is left unchanged.
+	assert.Equal(time.Hour, ormConfig.ConnectionLifetime)
+	assert.Equal(4, ormInstance.Stats().MaxOpenConnections)
+	assert.Equal(4, stats.MaxOpenConnections)
+	assert.Contains(out.String(), "connection lifetime: 2h0m0s, max open connections: 4")
+}
+
+func TestOrmStats_WithPoolSettings_ReturnsStats(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormConfig := &orm.Config{}
+	test.InitializeConfig(ormConfig)
+	ormConfig.MaxOpenConnections = 3
+	ormInstance := orm.NewORM(ormConfig, test.NewLogger())
+	ormInstance.Initialize()
+	defer ormInstance.Finalize()
+
+	// Act
+	stats := ormInstance.Stats()
+
+	// Assert
+	assert.Equal(3, stats.MaxOpenConnections)
+}
+
+func TestOrmSetStatsHook_WithStatsInterval_ReportsStats(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormConfig := &orm.Config{}
+	test.InitializeConfig(ormConfig)
+	ormConfig.StatsInterval = 10 * time.Millisecond
+	ormInstance := orm.NewORM(ormConfig, test.NewLogger())
+	reported := make(chan sql.DBStats, 10)
+	ormInstance.SetStatsHook(func(stats sql.DBStats) {
+		select {
+		case reported <- stats:
+		default:
+		}
+	})
+
+	// Act
+	ormInstance.Initialize()
+	var stats sql.DBStats
+	select {
+	case stats = <-reported:
+	case <-time.After(time.Second):
+	}
+	err := ormInstance.Finalize()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(1, stats.OpenConnections)
+}
+
+func TestOrmStats_NotInitialized_Panics(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	orm := orm.ORM{}
+
+	// Act
+	act := func() { orm.Stats() }
+
+	// Assert
+	assert.Panics(act)
+}
+
+func newReplicatedOrm(t *testing.T, policy string) *orm.ORM {
+	ormConfig := &orm.Config{}
+	test.InitializeConfig(ormConfig)
+	// in memory, each replica is an independent empty database.
+	ormConfig.Replicas = []string{"replica1", "replica2:5433"}
+	ormConfig.ReplicaPolicy = policy
+	ormInstance := orm.NewORM(ormConfig, test.NewLogger())
+	if err := ormInstance.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ormInstance.Finalize() })
+	if err := ormInstance.GetPrimaryDB().AutoMigrate(&TestModel{}); err != nil {
+		t.Fatal(err)
+	}
+	return ormInstance
+}
+
+func TestOrmGetDB_WithReplicas_RoutesReadsToReplicas(t *testing.T) {
+	for _, policy := range []string{orm.RoundRobinReplicaPolicy, orm.RandomReplicaPolicy} {
+		t.Run(policy, func(t *testing.T) {
+			// Arrange
+			assert := assert.New(t)
+			ormInstance := newReplicatedOrm(t, policy)
+
+			// Act
+			var models []TestModel
+			err := ormInstance.GetDB().Find(&models).Error
+			var count int64
+			err2 := ormInstance.GetDB().Model(&TestModel{}).Count(&count).Error
+
+			// Assert
+			assert.Error(err)
+			assert.Contains(err.Error(), "no such table")
+			assert.Error(err2)
+		})
+	}
+}
+
+func TestOrmGetDB_WithReplicas_ExecutesWritesOnPrimary(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := newReplicatedOrm(t, orm.RoundRobinReplicaPolicy)
+
+	// Act
+	err := ormInstance.GetDB().Create(&TestModel{Name: "name"}).Error
+	var models []TestModel
+	err2 := ormInstance.GetPrimaryDB().Find(&models).Error
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(err2)
+	assert.Len(models, 1)
+}
+
+func TestOrmGetDB_WithReplicasInTransaction_ReadsFromPrimary(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := newReplicatedOrm(t, orm.RoundRobinReplicaPolicy)
+	var models []TestModel
+
+	// Act
+	err := ormInstance.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&TestModel{Name: "name"}).Error; err != nil {
+			return err
+		}
+		return tx.Find(&models).Error
+	})
+
+	// Assert
+	assert.NoError(err)
+	assert.Len(models, 1)
+}
+
+func TestOrmGetDB_WithReplicasAndRawWrite_ExecutesOnPrimary(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := newReplicatedOrm(t, orm.RoundRobinReplicaPolicy)
+
+	// Act
+	rows, err := ormInstance.GetDB().Raw("INSERT INTO test_models (name) VALUES (?)", "name").Rows()
+	if err == nil {
+		// sqlite executes the statement when the rows are read.
+		for rows.Next() {
+		}
+		rows.Close()
+	}
+	var models []TestModel
+	err2 := ormInstance.GetPrimaryDB().Find(&models).Error
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(err2)
+	assert.Len(models, 1)
+}
+
+// captureRowConnPool returns the connection pool of the last raw query.
+func captureRowConnPool(t *testing.T, db *gorm.DB) *gorm.ConnPool {
+	var connPool gorm.ConnPool
+	err := db.Callback().Row().After("gorm:row").Register("test:capture", func(db *gorm.DB) {
+		connPool = db.Statement.ConnPool
+	})
+	require.NoError(t, err)
+	return &connPool
+}
+
+func TestOrmGetDB_WithReplicasAndRawLockingRead_ExecutesOnPrimary(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := newReplicatedOrm(t, orm.RoundRobinReplicaPolicy)
+	connPool := captureRowConnPool(t, ormInstance.GetDB())
+
+	// Act
+	var models []TestModel
+	ormInstance.GetDB().Raw("SELECT * FROM test_models FOR UPDATE").Scan(&models)
+
+	// Assert
+	assert.Same(ormInstance.GetDB().Statement.ConnPool, *connPool)
+}
+
+func TestOrmGetDB_WithReplicasAndRawRead_RoutesToReplica(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := newReplicatedOrm(t, orm.RoundRobinReplicaPolicy)
+	connPool := captureRowConnPool(t, ormInstance.GetDB())
+
+	// Act
+	var models []TestModel
+	err := ormInstance.GetDB().Raw("SELECT * FROM test_models").Scan(&models).Error
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "no such table")
+	assert.NotSame(ormInstance.GetDB().Statement.ConnPool, *connPool)
+}
+
+func TestOrmGetPrimaryDB_WithReplicas_ReadsFromPrimary(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := newReplicatedOrm(t, orm.RoundRobinReplicaPolicy)
+
+	// Act
+	var models []TestModel
+	err := ormInstance.GetPrimaryDB().WithContext(context.Background()).Find(&models).Error
+
+	// Assert
+	assert.NoError(err)
+}
+
+func TestOrmGetPrimaryDB_WithoutReplicas_ReturnsDB(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestModel{})
+
+	// Act
+	db := ormInstance.GetPrimaryDB()
+
+	// Assert
+	assert.Same(ormInstance.GetDB(), db)
+}
@@ -0,0 +1,272 @@
+package orm
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Default bounds of the page sizes of PageTokenCodec.ParsePageRequest.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+)
+
+// ErrInvalidPageToken is returned when a page token is malformed, was not
+// signed with the key of the codec or does not match the sort or the filters
+// of the query.
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// PageToken is the cursor of a page in keyset pagination: the values of the
+// sort fields of the last record of the previous page, and the sort they were
+// taken with.
+type PageToken struct {
+	// Order is the order of the query, e.g. ["rank DESC", "id"].
+	Order []string `json:"o"`
+	// Keys are the JSON encoded values of the fields of the order.
+	Keys []json.RawMessage `json:"k"`
+	// FilterDigest is the digest of the filters of the query (see
+	// DigestFilters), empty if the token is not bound to filters.
+	FilterDigest string `json:"f,omitempty"`
+}
+
+// PageRequest is the requested page of a query.
+type PageRequest struct {
+	// Token is the token of the page, nil for the first page.
+	Token *PageToken
+	// Size is the maximum number of records of the page.
+	Size int
+	// FilterDigest is the digest of the filters of the query (see
+	// DigestFilters). The token must have been created with the same digest,
+	// so that a token cannot be used with other filters than the ones of the
+	// query of the previous page.
+	FilterDigest string
+}
+
+// DigestFilters returns a digest of the given filters of a query (e.g. the
+// filter parameters of the request), to be set as PageRequest.FilterDigest.
+func DigestFilters(filters ...interface{}) (string, error) {
+	data, err := json.Marshal(filters)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode the filters")
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:16]), nil
+}
+
+// PageTokenCodec encodes the page tokens as opaque strings signed with an
+// HMAC-SHA256, so that the clients can neither forge nor alter them.
+type PageTokenCodec struct {
+	key []byte
+	// DefaultPageSize is the size of the pages requested without size.
+	DefaultPageSize int
+	// MaxPageSize is the maximum size of the pages, larger sizes being
+	// reduced to it.
+	MaxPageSize int
+}
+
+// NewPageTokenCodec creates a new PageTokenCodec signing the tokens with the
+// given key, which should be random and at least 32 bytes long (see
+// crypto.GenerateKey).
+func NewPageTokenCodec(key []byte) *PageTokenCodec {
+	return &PageTokenCodec{
+		key:             key,
+		DefaultPageSize: DefaultPageSize,
+		MaxPageSize:     MaxPageSize,
+	}
+}
+
+// Encode returns the signed string of the token, empty for a nil token.
+func (c *PageTokenCodec) Encode(token *PageToken) (string, error) {
+	if token == nil {
+		return "", nil
+	}
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode page token")
+	}
+	return base64.RawURLEncoding.EncodeToString(append(payload, c.sign(payload)...)), nil
+}
+
+// Decode returns the token of the given string, nil for an empty string, or
+// ErrInvalidPageToken if its signature is invalid.
+func (c *PageTokenCodec) Decode(value string) (*PageToken, error) {
+	if value == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) < sha256.Size {
+		return nil, ErrInvalidPageToken
+	}
+	payload, signature := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if !hmac.Equal(signature, c.sign(payload)) {
+		return nil, ErrInvalidPageToken
+	}
+	token := &PageToken{}
+	if err := json.Unmarshal(payload, token); err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	return token, nil
+}
+
+// ParsePageRequest returns the page request of the given token and size, e.g.
+// read from the page_token and page_size parameters of a request. A zero size
+// is replaced by the DefaultPageSize of the codec (the package DefaultPageSize
+// if not positive) and the sizes larger than MaxPageSize are reduced to it.
+func (c *PageTokenCodec) ParsePageRequest(token string, size int) (*PageRequest, error) {
+	if size < 0 {
+		return nil, errors.Errorf("invalid page size [%d]", size)
+	}
+	if size == 0 {
+		size = c.DefaultPageSize
+	}
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	if c.MaxPageSize > 0 && size > c.MaxPageSize {
+		size = c.MaxPageSize
+	}
+	pageToken, err := c.Decode(token)
+	if err != nil {
+		return nil, err
+	}
+	return &PageRequest{Token: pageToken, Size: size}, nil
+}
+
+func (c *PageTokenCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// ApplyPage orders db by the sort fields of the model followed by its primary
+// key, selects the records following the page token and limits the query to
+// one record more than the page size, which tells NextPageToken whether there
+// is a next page. The page token is only bound to the filters of db through
+// the FilterDigest of the page.
+// ex.)
+//	db, err := orm.ApplyPage(db.Where("owner = ?", owner), &User{}, sort, page)
+//	...
+//	err = db.Find(&users).Error
+//	...
+//	next, err := orm.NextPageToken(db, &users, sort, page)
+func ApplyPage(db *gorm.DB, model interface{}, sorts []Sort, page *PageRequest) (*gorm.DB, error) {
+	if err := page.validate(); err != nil {
+		return nil, err
+	}
+	s, err := parseSchema(db, model)
+	if err != nil {
+		return nil, err
+	}
+	fields, columns, err := sortColumns(s, sorts)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range columns {
+		db = db.Order(column)
+	}
+	if page.Token != nil {
+		after, err := page.Token.cursor(fields, columns, page.FilterDigest)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Clauses(clause.Where{Exprs: []clause.Expression{keysetCondition(columns, after)}})
+	}
+	return db.Limit(page.Size + 1), nil
+}
+
+// NextPageToken truncates the records (a pointer to a slice of models) found
+// by a query of ApplyPage to the page size, and returns the token of the next
+// page, nil on the last page.
+func NextPageToken(db *gorm.DB, records interface{}, sorts []Sort, page *PageRequest) (*PageToken, error) {
+	if err := page.validate(); err != nil {
+		return nil, err
+	}
+	value := reflect.ValueOf(records)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Slice {
+		return nil, errors.Errorf("records must be a pointer to a slice, got [%T]", records)
+	}
+	slice := value.Elem()
+	if slice.Len() <= page.Size {
+		return nil, nil
+	}
+	slice.Set(slice.Slice(0, page.Size))
+	s, err := parseSchema(db, records)
+	if err != nil {
+		return nil, err
+	}
+	fields, columns, err := sortColumns(s, sorts)
+	if err != nil {
+		return nil, err
+	}
+	return newPageToken(columns, cursorValues(fields, reflect.Indirect(slice.Index(page.Size-1))), page.FilterDigest)
+}
+
+func (p *PageRequest) validate() error {
+	if p == nil || p.Size <= 0 {
+		return errors.New("invalid page request, expecting a positive page size")
+	}
+	return nil
+}
+
+// newPageToken returns the token of the cursor values of the columns, bound to
+// the given filter digest.
+func newPageToken(columns []clause.OrderByColumn, values []interface{}, filterDigest string) (*PageToken, error) {
+	token := &PageToken{Order: orderNames(columns), FilterDigest: filterDigest}
+	for _, value := range values {
+		key, err := json.Marshal(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to encode page token")
+		}
+		token.Keys = append(token.Keys, key)
+	}
+	return token, nil
+}
+
+// cursor returns the values of the keys of the token, decoded as the types of
+// the fields. It returns ErrInvalidPageToken if the token was created with
+// another order or other filters.
+func (t *PageToken) cursor(fields []*schema.Field, columns []clause.OrderByColumn, filterDigest string) ([]interface{}, error) {
+	if !reflect.DeepEqual(t.Order, orderNames(columns)) || len(t.Keys) != len(fields) || t.FilterDigest != filterDigest {
+		return nil, ErrInvalidPageToken
+	}
+	res := make([]interface{}, len(fields))
+	for i, field := range fields {
+		value := reflect.New(field.FieldType)
+		if err := json.Unmarshal(t.Keys[i], value.Interface()); err != nil {
+			return nil, ErrInvalidPageToken
+		}
+		res[i] = value.Elem().Interface()
+	}
+	return res, nil
+}
+
+func orderNames(columns []clause.OrderByColumn) []string {
+	res := make([]string, len(columns))
+	for i, column := range columns {
+		res[i] = column.Column.Name
+		if column.Desc {
+			res[i] += " DESC"
+		}
+	}
+	return res
+}
+
+// parseSchema returns the schema of the model, cached by gorm.
+func parseSchema(db *gorm.DB, model interface{}) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse model [%T]", model)
+	}
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil, errors.Errorf("model [%s] has no single primary key", stmt.Schema.Name)
+	}
+	return stmt.Schema, nil
+}
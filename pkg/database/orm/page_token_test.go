@@ -0,0 +1,257 @@
+package orm_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var pageTokenKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestPageTokenCodec_EncodeDecode_ReturnsToken(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	codec := orm.NewPageTokenCodec(pageTokenKey)
+	token := &orm.PageToken{Order: []string{"rank DESC", "id"}, Keys: []json.RawMessage{json.RawMessage("2"), json.RawMessage("5")}}
+
+	// Act
+	value, err := codec.Encode(token)
+	decoded, err2 := codec.Decode(value)
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(err2)
+	assert.Equal(token, decoded)
+}
+
+func TestPageTokenCodec_Decode_InvalidTokens_ReturnsError(t *testing.T) {
+	// Arrange
+	codec := orm.NewPageTokenCodec(pageTokenKey)
+	value, _ := codec.Encode(&orm.PageToken{Order: []string{"id"}, Keys: []json.RawMessage{json.RawMessage("5")}})
+	otherKeyValue, _ := orm.NewPageTokenCodec([]byte("another key")).Encode(&orm.PageToken{Order: []string{"id"}})
+	tampered := []byte(value)
+	tampered[3] ^= 1
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"not base64", "not a token!"},
+		{"too short", "YWJj"},
+		{"tampered", string(tampered)},
+		{"other key", otherKeyValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			_, err := codec.Decode(tt.value)
+
+			// Assert
+			assert.Equal(t, orm.ErrInvalidPageToken, err)
+		})
+	}
+}
+
+func TestPageTokenCodec_ParsePageRequest_BoundsSize(t *testing.T) {
+	tests := []struct {
+		size     int
+		expected int
+	}{
+		{0, 10},
+		{5, 5},
+		{500, 100},
+	}
+	for _, tt := range tests {
+		// Arrange
+		codec := orm.NewPageTokenCodec(pageTokenKey)
+		codec.DefaultPageSize = 10
+		codec.MaxPageSize = 100
+
+		// Act
+		page, err := codec.ParsePageRequest("", tt.size)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Nil(t, page.Token)
+		assert.Equal(t, tt.expected, page.Size)
+	}
+}
+
+func TestPageTokenCodec_ParsePageRequest_NegativeSize_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	codec := orm.NewPageTokenCodec(pageTokenKey)
+
+	// Act
+	_, err := codec.ParsePageRequest("", -1)
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestPageTokenCodec_ParsePageRequest_WithoutDefaultSize_UsesPackageDefault(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	codec := orm.NewPageTokenCodec(pageTokenKey)
+	codec.DefaultPageSize = 0
+
+	// Act
+	page, err := codec.ParsePageRequest("", 0)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(orm.DefaultPageSize, page.Size)
+}
+
+func TestApplyPage_InvalidPageRequest_ReturnsError(t *testing.T) {
+	tests := []struct {
+		name string
+		page *orm.PageRequest
+	}{
+		{"nil", nil},
+		{"zero size", &orm.PageRequest{}},
+		{"negative size", &orm.PageRequest{Size: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			assert := assert.New(t)
+			ormInstance, _ := newItemRepository(t, "item1")
+			var items []TestRepositoryItem
+
+			// Act
+			_, err := orm.ApplyPage(ormInstance.GetDB(), &TestRepositoryItem{}, nil, tt.page)
+			_, err2 := orm.NextPageToken(ormInstance.GetDB(), &items, nil, tt.page)
+
+			// Assert
+			assert.Error(err)
+			assert.Error(err2)
+		})
+	}
+}
+
+func TestApplyPage_WithPageTokens_ReturnsAllPages(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, repository := newItemRepository(t)
+	createdAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, name := range []string{"item1", "item2", "item3", "item4", "item5"} {
+		// item4 and item5 have the same creation time.
+		item := &TestRepositoryItem{Name: name, CreatedAt: createdAt.Add(time.Duration(i-i/4) * time.Second)}
+		repository.Create(context.Background(), item)
+	}
+	codec := orm.NewPageTokenCodec(pageTokenKey)
+	sort := []orm.Sort{{Field: "CreatedAt", Desc: true}}
+	value := ""
+
+	// Act
+	var names []string
+	for {
+		page, err := codec.ParsePageRequest(value, 2)
+		if !assert.NoError(err) {
+			return
+		}
+		db, err := orm.ApplyPage(ormInstance.GetDB(), &TestRepositoryItem{}, sort, page)
+		if !assert.NoError(err) {
+			return
+		}
+		var items []TestRepositoryItem
+		if !assert.NoError(db.Find(&items).Error) {
+			return
+		}
+		next, err := orm.NextPageToken(ormInstance.GetDB(), &items, sort, page)
+		if !assert.NoError(err) {
+			return
+		}
+		names = append(names, itemNames(items)...)
+		if next == nil {
+			break
+		}
+		value, _ = codec.Encode(next)
+	}
+
+	// Assert
+	assert.Equal([]string{"item4", "item5", "item3", "item2", "item1"}, names)
+}
+
+func TestApplyPage_TokenOfAnotherSort_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, _ := newItemRepository(t, "item1", "item2", "item3")
+	page := &orm.PageRequest{Size: 1}
+	var items []TestRepositoryItem
+	db, _ := orm.ApplyPage(ormInstance.GetDB(), &TestRepositoryItem{}, nil, page)
+	db.Find(&items)
+	page.Token, _ = orm.NextPageToken(ormInstance.GetDB(), &items, nil, page)
+
+	// Act
+	_, err := orm.ApplyPage(ormInstance.GetDB(), &TestRepositoryItem{}, []orm.Sort{{Field: "Name"}}, page)
+
+	// Assert
+	assert.Equal(orm.ErrInvalidPageToken, err)
+}
+
+func TestApplyPage_TokenOfOtherFilters_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, _ := newItemRepository(t, "item1", "item2", "item3")
+	digest, _ := orm.DigestFilters("category", "a")
+	otherDigest, _ := orm.DigestFilters("category", "b")
+	page := &orm.PageRequest{Size: 1, FilterDigest: digest}
+	var items []TestRepositoryItem
+	db, _ := orm.ApplyPage(ormInstance.GetDB(), &TestRepositoryItem{}, nil, page)
+	db.Find(&items)
+	token, _ := orm.NextPageToken(ormInstance.GetDB(), &items, nil, page)
+
+	// Act
+	_, err := orm.ApplyPage(ormInstance.GetDB(), &TestRepositoryItem{}, nil, &orm.PageRequest{Token: token, Size: 1, FilterDigest: otherDigest})
+	_, err2 := orm.ApplyPage(ormInstance.GetDB(), &TestRepositoryItem{}, nil, &orm.PageRequest{Token: token, Size: 1, FilterDigest: digest})
+
+	// Assert
+	assert.Equal(orm.ErrInvalidPageToken, err)
+	assert.NoError(err2)
+}
+
+func TestRepositoryList_WithPageToken_ReturnsNextPage(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1", "item2", "item3")
+	codec := orm.NewPageTokenCodec(pageTokenKey)
+	sort := []orm.Sort{{Field: "Name", Desc: true}}
+	first, _ := repository.List(context.Background(), &orm.ListOptions{Sort: sort, Limit: 2})
+	value, _ := codec.Encode(first.NextPageToken)
+	token, _ := codec.Decode(value)
+
+	// Act
+	page, err := repository.List(context.Background(), &orm.ListOptions{Sort: sort, Limit: 2, PageToken: token})
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]string{"item3", "item2"}, itemNames(first.Items))
+	assert.Equal([]string{"item1"}, itemNames(page.Items))
+	assert.Nil(page.NextPageToken)
+}
+
+func TestRepositoryList_PageTokenOfOtherFilters_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1", "item2", "item3", "item4")
+	first, _ := repository.List(context.Background(), &orm.ListOptions{
+		Filters: []orm.Filter{{Field: "Category", Value: "a"}},
+		Limit:   1,
+	})
+
+	// Act
+	_, err := repository.List(context.Background(), &orm.ListOptions{
+		Filters:   []orm.Filter{{Field: "Category", Value: "b"}},
+		Limit:     1,
+		PageToken: first.NextPageToken,
+	})
+
+	// Assert
+	assert.Equal(orm.ErrInvalidPageToken, err)
+}
@@ -0,0 +1,221 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"net"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	// RoundRobinReplicaPolicy routes the reads to the healthy replicas in turn.
+	RoundRobinReplicaPolicy = "round_robin"
+	// RandomReplicaPolicy routes the reads to a random healthy replica.
+	RandomReplicaPolicy = "random"
+
+	replicaCallbackName = "orm:replica"
+)
+
+// lockingClauseRegex matches the locking clauses of the raw SQL queries.
+var lockingClauseRegex = regexp.MustCompile(`(?i)\bFOR\s+(NO\s+KEY\s+)?(UPDATE|SHARE|KEY\s+SHARE)\b|\bLOCK\s+IN\s+SHARE\s+MODE\b`)
+
+// primaryConnPool marks the statements which must be executed by the primary.
+type primaryConnPool struct {
+	*sql.DB
+}
+
+// replica is a read replica of the database.
+type replica struct {
+	host    string
+	db      *sql.DB
+	healthy int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+// setHealthy updates the health of the replica and returns whether it changed.
+func (r *replica) setHealthy(healthy bool) bool {
+	var value int32
+	if healthy {
+		value = 1
+	}
+	return atomic.SwapInt32(&r.healthy, value) != value
+}
+
+// replicaSet routes the reads to the healthy replicas.
+type replicaSet struct {
+	replicas []*replica
+	policy   string
+	next     uint32
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// pick returns a healthy replica according to the policy, or nil if all the
+// replicas are unhealthy.
+func (s *replicaSet) pick() *replica {
+	healthy := make([]*replica, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		if r.isHealthy() {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	if s.policy == RandomReplicaPolicy {
+		return healthy[rand.Intn(len(healthy))]
+	}
+	return healthy[int(atomic.AddUint32(&s.next, 1)-1)%len(healthy)]
+}
+
+func (s *replicaSet) find(connPool gorm.ConnPool) *replica {
+	for _, r := range s.replicas {
+		if connPool == gorm.ConnPool(r.db) {
+			return r
+		}
+	}
+	return nil
+}
+
+// initializeReplicas opens the connections to the replicas of the
+// configuration and routes the reads of the DB to them.
+func (o *ORM) initializeReplicas(gormConfig *gorm.Config) error {
+	config := o.getConfig()
+	if len(config.Replicas) == 0 {
+		return nil
+	}
+	set := &replicaSet{policy: config.ReplicaPolicy}
+	for _, address := range config.Replicas {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			host, port = address, config.Port
+		}
+		opened, err := gorm.Open(o.dialector(o.connectionString(host, port)), gormConfig)
+		if err != nil {
+			set.close()
+			return errors.Wrapf(err, "failed to open database replica [%s]", address)
+		}
+		sqldb, err := opened.DB()
+		if err != nil {
+			set.close()
+			return errors.Wrapf(err, "failed to access database replica [%s]", address)
+		}
+		set.replicas = append(set.replicas, &replica{host: address, db: sqldb, healthy: 1})
+	}
+	o.replicas = set
+
+	callbacks := o.db.Callback()
+	if err := callbacks.Query().Before("gorm:query").Register(replicaCallbackName, o.routeRead); err != nil {
+		return errors.Wrap(err, "failed to register the replica routing")
+	}
+	if err := callbacks.Row().Before("gorm:row").Register(replicaCallbackName, o.routeRead); err != nil {
+		return errors.Wrap(err, "failed to register the replica routing")
+	}
+	if err := callbacks.Query().After("gorm:query").Register(replicaCallbackName+"_check", o.checkReplica); err != nil {
+		return errors.Wrap(err, "failed to register the replica routing")
+	}
+
+	o.startReplicaHealthChecks(config.ReplicaHealthCheckInterval)
+	return nil
+}
+
+// routeRead routes the queries to a replica, except within transactions, for
+// the statements of GetPrimaryDB, including the ones of the migration dry
+// runs, and for locking reads (SELECT ... FOR). The raw statements are routed
+// only if they are SELECT or SHOW queries without locking clause.
+func (o *ORM) routeRead(db *gorm.DB) {
+	switch db.Statement.ConnPool.(type) {
+	case gorm.TxCommitter, *primaryConnPool, *recordingConnPool:
+		return
+	}
+	if _, ok := db.Statement.Clauses["FOR"]; ok {
+		return
+	}
+	if sql := db.Statement.SQL.String(); sql != "" && (!isReadQuery(sql) || lockingClauseRegex.MatchString(sql)) {
+		return
+	}
+	if r := o.replicas.pick(); r != nil {
+		db.Statement.ConnPool = r.db
+	}
+}
+
+// checkReplica ejects the replica which executed the query if its connection
+// was lost.
+func (o *ORM) checkReplica(db *gorm.DB) {
+	if ErrorCategoryOf(db.Error) != ConnectionLost {
+		return
+	}
+	if r := o.replicas.find(db.Statement.ConnPool); r != nil && r.setHealthy(false) {
+		o.log.Logger.Warnf("database replica [%s] ejected: %v", r.host, db.Error)
+	}
+}
+
+// startReplicaHealthChecks pings the replicas at the given interval, the
+// replicas failing being ejected until they answer again.
+func (o *ORM) startReplicaHealthChecks(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	set := o.replicas
+	set.stop, set.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(set.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-set.stop:
+				return
+			case <-ticker.C:
+				o.checkReplicasHealth(interval)
+			}
+		}
+	}()
+}
+
+func (o *ORM) checkReplicasHealth(timeout time.Duration) {
+	var wg sync.WaitGroup
+	for _, r := range o.replicas.replicas {
+		wg.Add(1)
+		go func(r *replica) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			err := r.db.PingContext(ctx)
+			if !r.setHealthy(err == nil) {
+				return
+			}
+			if err != nil {
+				o.log.Logger.Warnf("database replica [%s] ejected: %v", r.host, err)
+			} else {
+				o.log.Logger.Infof("database replica [%s] is healthy again", r.host)
+			}
+		}(r)
+	}
+	wg.Wait()
+}
+
+// close stops the health checks and closes the connections to the replicas.
+func (s *replicaSet) close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+	var res error
+	for _, r := range s.replicas {
+		if err := r.db.Close(); err != nil {
+			res = errors.Wrapf(err, "failed to close database replica [%s]", r.host)
+		}
+	}
+	return res
+}
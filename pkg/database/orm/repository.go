@@ -0,0 +1,443 @@
+package orm
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Operators of the filters.
+const (
+	EqualOperator          = "="
+	NotEqualOperator       = "<>"
+	LessOperator           = "<"
+	LessOrEqualOperator    = "<="
+	GreaterOperator        = ">"
+	GreaterOrEqualOperator = ">="
+	// InOperator expects a slice value.
+	InOperator   = "IN"
+	LikeOperator = "LIKE"
+)
+
+// Filter restricts the records to those whose field compares with Value
+// according to Operator (EqualOperator if empty). A nil Value matches the
+// NULL columns with EqualOperator and the non NULL ones with NotEqualOperator.
+type Filter struct {
+	// Field is the name of a field of the model or of its column.
+	Field    string
+	Operator string
+	Value    interface{}
+}
+
+// Sort orders the records by a field of the model (or its column).
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions are the options of Repository.List.
+type ListOptions struct {
+	Filters []Filter
+	// Sort orders the records, the primary key being appended to make the
+	// order total. The records are ordered by primary key by default.
+	Sort []Sort
+	// Limit is the maximum number of records of the page, unlimited if zero.
+	Limit int
+	// Offset is the number of records skipped (offset pagination).
+	Offset int
+	// After is the NextCursor of the previous page (cursor pagination): the
+	// values of the sort fields of its last record. The sort fields should not
+	// be NULL.
+	After []interface{}
+	// PageToken is the NextPageToken of the previous page, the signed
+	// alternative to After for the cursors sent to the clients (see
+	// PageTokenCodec). The token is bound to the filters of the previous page.
+	PageToken *PageToken
+	// WithDeleted includes the soft deleted records.
+	WithDeleted bool
+}
+
+// Page is a page of records returned by Repository.List.
+type Page[T any] struct {
+	Items []T
+	// NextCursor is the cursor of the next page to pass as the After option
+	// with the same filters and sort, nil on the last page.
+	NextCursor []interface{}
+	// NextPageToken is the token of NextCursor, nil on the last page.
+	NextPageToken *PageToken
+}
+
+// Repository provides the CRUD operations of the model T. The operations use
+// the transaction carried by their context if any (see ORM.WithTransaction).
+// The model must have a single primary key. Models with a gorm.DeletedAt
+// field are soft deleted.
+// ex.) users := orm.NewRepository[model.User](ormInstance)
+type Repository[T any] struct {
+	orm *ORM
+}
+
+// NewRepository creates a new Repository of the model T.
+func NewRepository[T any](orm *ORM) *Repository[T] {
+	return &Repository[T]{orm: orm}
+}
+
+// Get returns the record with the given primary key, or gorm.ErrRecordNotFound
+// (see IsRecordNotFoundError).
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	s, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+	var entity T
+	if err := r.getDB(ctx).Where(primaryKeyCondition(s, id)).Take(&entity).Error; err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// List returns the page of records matching the options, opts can be nil.
+func (r *Repository[T]) List(ctx context.Context, opts *ListOptions) (*Page[T], error) {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+	s, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+	db := r.getDB(ctx)
+	if opts.WithDeleted {
+		db = db.Unscoped()
+	}
+	if db, err = applyFilters(db, s, opts.Filters); err != nil {
+		return nil, err
+	}
+	fields, columns, err := sortColumns(s, opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range columns {
+		db = db.Order(column)
+	}
+	filterDigest, err := DigestFilters(opts.Filters, opts.WithDeleted)
+	if err != nil {
+		return nil, err
+	}
+	after := opts.After
+	if opts.PageToken != nil {
+		if after, err = opts.PageToken.cursor(fields, columns, filterDigest); err != nil {
+			return nil, err
+		}
+	}
+	if after != nil {
+		if len(after) != len(columns) {
+			return nil, errors.Errorf("invalid cursor, expecting %d values", len(columns))
+		}
+		db = db.Clauses(clause.Where{Exprs: []clause.Expression{keysetCondition(columns, after)}})
+	}
+	if opts.Offset > 0 {
+		db = db.Offset(opts.Offset)
+	}
+	if opts.Limit > 0 {
+		// the additional record tells whether there is a next page.
+		db = db.Limit(opts.Limit + 1)
+	}
+
+	var items []T
+	if err := db.Find(&items).Error; err != nil {
+		return nil, err
+	}
+	page := &Page[T]{Items: items}
+	if opts.Limit > 0 && len(items) > opts.Limit {
+		page.Items = items[:opts.Limit]
+		page.NextCursor = cursorValues(fields, reflect.ValueOf(&page.Items[opts.Limit-1]).Elem())
+		if page.NextPageToken, err = newPageToken(columns, page.NextCursor, filterDigest); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// Count returns the number of records matching the filters.
+func (r *Repository[T]) Count(ctx context.Context, filters ...Filter) (int64, error) {
+	s, err := r.schema()
+	if err != nil {
+		return 0, err
+	}
+	db, err := applyFilters(r.getDB(ctx).Model(new(T)), s, filters)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := db.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Create inserts the record, setting its primary key and timestamps.
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	return r.getDB(ctx).Create(entity).Error
+}
+
+// Update updates the record with the primary key of the entity. Only the given
+// fields are updated (field mask), zero values included, or all the fields
+// except the primary key and the creation time if none is given. Returns
+// gorm.ErrRecordNotFound if the record does not exist.
+func (r *Repository[T]) Update(ctx context.Context, entity *T, fields ...string) error {
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	value := reflect.ValueOf(entity).Elem()
+	if _, zero := s.PrioritizedPrimaryField.ValueOf(value); zero {
+		return errors.Errorf("cannot update [%s] without primary key", s.Name)
+	}
+	columns, err := updateColumns(s, fields)
+	if err != nil {
+		return err
+	}
+	res := r.getDB(ctx).Model(entity).Select(columns).Updates(entity)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Upsert inserts the record, or updates all its fields except the primary key
+// and the creation time if it conflicts on the given fields (the primary key
+// by default) with an existing record. With sqlite, the primary key of the
+// entity is not set when the record is updated.
+func (r *Repository[T]) Upsert(ctx context.Context, entity *T, conflictFields ...string) error {
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	conflict := s.PrimaryFieldDBNames
+	if len(conflictFields) > 0 {
+		if conflict, err = columnNames(s, conflictFields); err != nil {
+			return err
+		}
+	}
+	columns, err := updateColumns(s, nil)
+	if err != nil {
+		return err
+	}
+	onConflict := clause.OnConflict{DoUpdates: clause.AssignmentColumns(columns)}
+	for _, name := range conflict {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: name})
+	}
+	return r.getDB(ctx).Clauses(onConflict).Create(entity).Error
+}
+
+// Delete deletes the record with the given primary key, soft deleting it if
+// the model has a gorm.DeletedAt field. Returns gorm.ErrRecordNotFound if the
+// record does not exist.
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	return r.delete(r.getDB(ctx), id, false)
+}
+
+// HardDelete deletes the record with the given primary key, including a soft
+// deleted record. Returns gorm.ErrRecordNotFound if the record does not exist.
+func (r *Repository[T]) HardDelete(ctx context.Context, id interface{}) error {
+	return r.delete(r.getDB(ctx).Unscoped(), id, true)
+}
+
+func (r *Repository[T]) delete(db *gorm.DB, id interface{}, unscoped bool) error {
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	db = db.Where(primaryKeyCondition(s, id))
+	if field := deletedAtField(s); field != nil && !unscoped {
+		// gorm soft deletes the soft deleted records again.
+		db = db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}})
+	}
+	res := db.Delete(new(T))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// getDB returns the DB of the operations.
+func (r *Repository[T]) getDB(ctx context.Context) *gorm.DB {
+	return r.orm.GetContextDB(ctx)
+}
+
+// schema returns the schema of the model.
+func (r *Repository[T]) schema() (*schema.Schema, error) {
+	return parseSchema(r.orm.GetDB(), new(T))
+}
+
+// deletedAtField returns the gorm.DeletedAt field of the model, if any.
+func deletedAtField(s *schema.Schema) *schema.Field {
+	for _, field := range s.Fields {
+		if field.DBName != "" && field.FieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+			return field
+		}
+	}
+	return nil
+}
+
+// lookUpField returns the field of the model with the given name or column.
+func lookUpField(s *schema.Schema, name string) (*schema.Field, error) {
+	field := s.LookUpField(name)
+	if field == nil || field.DBName == "" {
+		return nil, errors.Errorf("unknown field [%s] of [%s]", name, s.Name)
+	}
+	return field, nil
+}
+
+func columnNames(s *schema.Schema, fields []string) ([]string, error) {
+	res := make([]string, 0, len(fields))
+	for _, name := range fields {
+		field, err := lookUpField(s, name)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, field.DBName)
+	}
+	return res, nil
+}
+
+// updateColumns returns the columns of the given fields, or of all the
+// updatable fields except the primary keys and the creation time, the update
+// time being always included.
+func updateColumns(s *schema.Schema, fields []string) ([]string, error) {
+	if len(fields) > 0 {
+		res, err := columnNames(s, fields)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range s.Fields {
+			if field.DBName != "" && field.AutoUpdateTime != 0 {
+				res = append(res, field.DBName)
+			}
+		}
+		return res, nil
+	}
+	var res []string
+	for _, field := range s.Fields {
+		if field.DBName != "" && field.Updatable && !field.PrimaryKey && field.AutoCreateTime == 0 {
+			res = append(res, field.DBName)
+		}
+	}
+	return res, nil
+}
+
+func primaryKeyCondition(s *schema.Schema, id interface{}) clause.Expression {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: s.PrioritizedPrimaryField.DBName}, Value: id}
+}
+
+// applyFilters adds the conditions of the filters to db.
+func applyFilters(db *gorm.DB, s *schema.Schema, filters []Filter) (*gorm.DB, error) {
+	for _, filter := range filters {
+		field, err := lookUpField(s, filter.Field)
+		if err != nil {
+			return nil, err
+		}
+		column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+		var expr clause.Expression
+		switch filter.Operator {
+		case EqualOperator, "":
+			expr = clause.Eq{Column: column, Value: filter.Value}
+		case NotEqualOperator:
+			expr = clause.Neq{Column: column, Value: filter.Value}
+		case LessOperator:
+			expr = clause.Lt{Column: column, Value: filter.Value}
+		case LessOrEqualOperator:
+			expr = clause.Lte{Column: column, Value: filter.Value}
+		case GreaterOperator:
+			expr = clause.Gt{Column: column, Value: filter.Value}
+		case GreaterOrEqualOperator:
+			expr = clause.Gte{Column: column, Value: filter.Value}
+		case LikeOperator:
+			expr = clause.Like{Column: column, Value: filter.Value}
+		case InOperator:
+			values := reflect.ValueOf(filter.Value)
+			if values.Kind() != reflect.Slice {
+				return nil, errors.Errorf("operator IN of field [%s] expects a slice", filter.Field)
+			}
+			in := clause.IN{Column: column}
+			for i := 0; i < values.Len(); i++ {
+				in.Values = append(in.Values, values.Index(i).Interface())
+			}
+			expr = in
+		default:
+			return nil, errors.Errorf("unknown operator [%s] of field [%s]", filter.Operator, filter.Field)
+		}
+		db = db.Clauses(clause.Where{Exprs: []clause.Expression{expr}})
+	}
+	return db, nil
+}
+
+// sortColumns returns the fields and the order of the given sort, followed
+// by the primary key if it is not part of the sort.
+func sortColumns(s *schema.Schema, sorts []Sort) ([]*schema.Field, []clause.OrderByColumn, error) {
+	var fields []*schema.Field
+	var columns []clause.OrderByColumn
+	primaryKey := s.PrioritizedPrimaryField
+	hasPrimaryKey := false
+	for _, sort := range sorts {
+		field, err := lookUpField(s, sort.Field)
+		if err != nil {
+			return nil, nil, err
+		}
+		hasPrimaryKey = hasPrimaryKey || field == primaryKey
+		fields = append(fields, field)
+		columns = append(columns, clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+			Desc:   sort.Desc,
+		})
+	}
+	if !hasPrimaryKey {
+		fields = append(fields, primaryKey)
+		columns = append(columns, clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: primaryKey.DBName},
+		})
+	}
+	return fields, columns, nil
+}
+
+// keysetCondition returns the condition selecting the records following the
+// given values in the order of the columns:
+// (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ...
+func keysetCondition(columns []clause.OrderByColumn, values []interface{}) clause.Expression {
+	var or []clause.Expression
+	for i, column := range columns {
+		var and []clause.Expression
+		for j := 0; j < i; j++ {
+			and = append(and, clause.Eq{Column: columns[j].Column, Value: values[j]})
+		}
+		if column.Desc {
+			and = append(and, clause.Lt{Column: column.Column, Value: values[i]})
+		} else {
+			and = append(and, clause.Gt{Column: column.Column, Value: values[i]})
+		}
+		or = append(or, clause.And(and...))
+	}
+	if len(or) == 1 {
+		// gorm joins a single OR condition with OR to the previous ones.
+		return or[0]
+	}
+	return clause.Or(or...)
+}
+
+// cursorValues returns the values of the fields of the record.
+func cursorValues(fields []*schema.Field, record reflect.Value) []interface{} {
+	res := make([]interface{}, len(fields))
+	for i, field := range fields {
+		res[i], _ = field.ValueOf(record)
+	}
+	return res
+}
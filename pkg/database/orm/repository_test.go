@@ -0,0 +1,292 @@
+package orm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
+	"github.com/cryptogarageinc/server-common-go/test"
+	"github.com/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type TestRepositoryItem struct {
+	ID        uint
+	Name      string `gorm:"uniqueIndex"`
+	Category  string
+	Rank      int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+func newItemRepository(t *testing.T, names ...string) (*orm.ORM, *orm.Repository[TestRepositoryItem]) {
+	ormInstance := test.NewOrm(&TestRepositoryItem{})
+	t.Cleanup(func() { ormInstance.Finalize() })
+	repository := orm.NewRepository[TestRepositoryItem](ormInstance)
+	for i, name := range names {
+		item := &TestRepositoryItem{Name: name, Category: []string{"a", "b"}[i%2], Rank: i % 3}
+		if err := repository.Create(context.Background(), item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ormInstance, repository
+}
+
+func itemNames(items []TestRepositoryItem) []string {
+	res := make([]string, 0, len(items))
+	for _, item := range items {
+		res = append(res, item.Name)
+	}
+	return res
+}
+
+func TestRepositoryGet_Exists_ReturnsRecord(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1", "item2")
+
+	// Act
+	item, err := repository.Get(context.Background(), 2)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("item2", item.Name)
+}
+
+func TestRepositoryGet_NotExists_ReturnsNotFound(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1")
+
+	// Act
+	_, err := repository.Get(context.Background(), 2)
+
+	// Assert
+	assert.True(orm.IsRecordNotFoundError(err))
+}
+
+func TestRepositoryList_WithFiltersAndSort_ReturnsRecords(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1", "item2", "item3", "item4", "item5")
+	opts := &orm.ListOptions{
+		Filters: []orm.Filter{
+			{Field: "Category", Value: "a"},
+			{Field: "rank", Operator: orm.InOperator, Value: []int{0, 1}},
+		},
+		Sort: []orm.Sort{{Field: "Name", Desc: true}},
+	}
+
+	// Act
+	page, err := repository.List(context.Background(), opts)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]string{"item5", "item1"}, itemNames(page.Items))
+	assert.Nil(page.NextCursor)
+}
+
+func TestRepositoryList_WithOffset_ReturnsPage(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1", "item2", "item3", "item4", "item5")
+
+	// Act
+	page, err := repository.List(context.Background(), &orm.ListOptions{Offset: 2, Limit: 2})
+	last, err2 := repository.List(context.Background(), &orm.ListOptions{Offset: 4, Limit: 2})
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(err2)
+	assert.Equal([]string{"item3", "item4"}, itemNames(page.Items))
+	assert.NotNil(page.NextCursor)
+	assert.Equal([]string{"item5"}, itemNames(last.Items))
+	assert.Nil(last.NextCursor)
+}
+
+func TestRepositoryList_WithCursor_ReturnsAllPages(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1", "item2", "item3", "item4", "item5", "item6", "item7")
+	opts := &orm.ListOptions{
+		Filters: []orm.Filter{{Field: "Name", Operator: orm.NotEqualOperator, Value: "item7"}},
+		Sort:    []orm.Sort{{Field: "Rank", Desc: true}},
+		Limit:   2,
+	}
+
+	// Act
+	var names []string
+	pages := 0
+	for {
+		page, err := repository.List(context.Background(), opts)
+		if !assert.NoError(err) {
+			return
+		}
+		pages++
+		names = append(names, itemNames(page.Items)...)
+		if page.NextCursor == nil {
+			break
+		}
+		opts.After = page.NextCursor
+	}
+
+	// Assert
+	assert.Equal(3, pages)
+	assert.Equal([]string{"item3", "item6", "item2", "item5", "item1", "item4"}, names)
+}
+
+func TestRepositoryList_InvalidField_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t)
+	opts := &orm.ListOptions{Sort: []orm.Sort{{Field: "name; DROP TABLE test_repository_items"}}}
+
+	// Act
+	_, err := repository.List(context.Background(), opts)
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "unknown field")
+}
+
+func TestRepositoryCount_WithFilter_ReturnsCount(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1", "item2", "item3")
+
+	// Act
+	count, err := repository.Count(context.Background(), orm.Filter{Field: "Rank", Operator: orm.GreaterOperator, Value: 0})
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(int64(2), count)
+}
+
+func TestRepositoryUpdate_WithFieldMask_UpdatesFields(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1", "item2")
+	item, _ := repository.Get(context.Background(), 2)
+	updatedAt := item.UpdatedAt
+
+	// Act
+	err := repository.Update(context.Background(), &TestRepositoryItem{ID: 2, Name: "new", Rank: 0}, "Rank")
+	updated, _ := repository.Get(context.Background(), 2)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("item2", updated.Name)
+	assert.Equal(0, updated.Rank)
+	assert.True(updated.UpdatedAt.After(updatedAt))
+}
+
+func TestRepositoryUpdate_WithoutFieldMask_UpdatesAllFields(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1", "item2")
+	item, _ := repository.Get(context.Background(), 2)
+
+	// Act
+	err := repository.Update(context.Background(), &TestRepositoryItem{ID: 2, Name: "new"})
+	updated, _ := repository.Get(context.Background(), 2)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("new", updated.Name)
+	assert.Equal("", updated.Category)
+	assert.Equal(item.CreatedAt.Unix(), updated.CreatedAt.Unix())
+}
+
+func TestRepositoryUpdate_NotExists_ReturnsNotFound(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1")
+
+	// Act
+	err := repository.Update(context.Background(), &TestRepositoryItem{ID: 2, Name: "new"}, "Name")
+	err2 := repository.Update(context.Background(), &TestRepositoryItem{Name: "new"}, "Name")
+
+	// Assert
+	assert.True(orm.IsRecordNotFoundError(err))
+	assert.Error(err2)
+}
+
+func TestRepositoryDelete_SoftDeletableModel_SoftDeletes(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1", "item2")
+
+	// Act
+	err := repository.Delete(context.Background(), 1)
+	_, getErr := repository.Get(context.Background(), 1)
+	page, _ := repository.List(context.Background(), &orm.ListOptions{WithDeleted: true})
+	err2 := repository.Delete(context.Background(), 1)
+
+	// Assert
+	assert.NoError(err)
+	assert.True(orm.IsRecordNotFoundError(getErr))
+	assert.Len(page.Items, 2)
+	assert.True(orm.IsRecordNotFoundError(err2))
+}
+
+func TestRepositoryHardDelete_SoftDeletedRecord_Deletes(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1", "item2")
+	repository.Delete(context.Background(), 1)
+
+	// Act
+	err := repository.HardDelete(context.Background(), 1)
+	page, _ := repository.List(context.Background(), &orm.ListOptions{WithDeleted: true})
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]string{"item2"}, itemNames(page.Items))
+}
+
+func TestRepositoryUpsert_Conflict_UpdatesRecord(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, repository := newItemRepository(t, "item1", "item2")
+
+	// Act
+	err := repository.Upsert(context.Background(), &TestRepositoryItem{Name: "item2", Category: "c"}, "Name")
+	err2 := repository.Upsert(context.Background(), &TestRepositoryItem{Name: "item3", Category: "c"}, "Name")
+	count, _ := repository.Count(context.Background(), orm.Filter{Field: "Category", Value: "c"})
+	total, _ := repository.Count(context.Background())
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(err2)
+	assert.Equal(int64(2), count)
+	assert.Equal(int64(3), total)
+}
+
+func TestRepository_InTransaction_UsesTransaction(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance, repository := newItemRepository(t)
+	var count int64
+
+	// Act
+	err := ormInstance.WithTransaction(context.Background(), nil, func(ctx context.Context, tx *gorm.DB) error {
+		if err := repository.Create(ctx, &TestRepositoryItem{Name: "item1"}); err != nil {
+			return err
+		}
+		var err error
+		if count, err = repository.Count(ctx); err != nil {
+			return err
+		}
+		return errors.New("error")
+	})
+	total, _ := repository.Count(context.Background())
+
+	// Assert
+	assert.Error(err)
+	assert.Equal(int64(1), count)
+	assert.Equal(int64(0), total)
+}
@@ -0,0 +1,148 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// TxOptions are the options of a transaction started by WithTransaction.
+type TxOptions struct {
+	// Isolation is the isolation level, the default of the database if zero.
+	Isolation sql.IsolationLevel
+	// ReadOnly starts a read only transaction. sqlite ignores the isolation
+	// level and the read only mode.
+	ReadOnly bool
+	// MaxRetries is the maximum number of retries on serialization failures
+	// and deadlocks, the TransactionMaxRetries of the orm configuration if
+	// zero, no retry if negative.
+	MaxRetries int
+}
+
+// TxFunc is the function run in a transaction, ctx carrying the transaction.
+type TxFunc func(ctx context.Context, tx *gorm.DB) error
+
+type txContextKey struct{}
+
+// savepointID makes the names of the savepoints unique.
+var savepointID uint64
+
+// ContextWithTx returns a copy of ctx carrying the transaction tx.
+func ContextWithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx, if any.
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*gorm.DB)
+	return tx, ok && tx != nil
+}
+
+// GetContextDB returns the transaction carried by ctx, or else the DB returned
+// by GetDB bound to ctx. Repositories use it to take part in the transactions
+// started by WithTransaction. Panics if the object is not initialized.
+func (o *ORM) GetContextDB(ctx context.Context) *gorm.DB {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return o.GetDB().WithContext(ctx)
+}
+
+// WithTransaction runs fn in a transaction on the primary database, committed
+// if fn returns nil and rolled back otherwise. The context passed to fn
+// carries the transaction (see GetContextDB).
+// If ctx already carries a transaction, fn is run in a savepoint of it, only
+// the statements of fn being rolled back on error, and opts is ignored.
+// Otherwise the transaction is retried with an exponential backoff when it
+// fails on a serialization failure or a deadlock, fn being run again.
+// opts can be nil.
+func (o *ORM) WithTransaction(ctx context.Context, opts *TxOptions, fn TxFunc) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		return withSavepoint(ctx, tx, fn)
+	}
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	config := o.getConfig()
+	maxRetries := opts.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = config.TransactionMaxRetries
+	}
+	backoff := config.TransactionRetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := o.transaction(ctx, opts, fn)
+		if err == nil || attempt >= maxRetries || !IsRetryableTxError(err) {
+			return err
+		}
+		delay := backoff << attempt
+		if delay > 0 {
+			// the jitter spreads the retries of the conflicting transactions.
+			delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+		}
+		o.log.Logger.Warnf("transaction failed, retrying in %s (%d/%d): %v", delay, attempt+1, maxRetries, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// transaction runs fn in a new transaction.
+func (o *ORM) transaction(ctx context.Context, opts *TxOptions, fn TxFunc) (err error) {
+	tx := o.GetPrimaryDB().WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: opts.Isolation,
+		ReadOnly:  opts.ReadOnly,
+	})
+	if tx.Error != nil {
+		return errors.Wrap(tx.Error, "failed to begin transaction")
+	}
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = fn(ContextWithTx(ctx, tx), tx)
+	if err == nil {
+		err = tx.Commit().Error
+	}
+	panicked = false
+	return err
+}
+
+// withSavepoint runs fn in a savepoint of the transaction tx.
+func withSavepoint(ctx context.Context, tx *gorm.DB, fn TxFunc) (err error) {
+	name := fmt.Sprintf("sp%d", atomic.AddUint64(&savepointID, 1))
+	if err := tx.Exec("SAVEPOINT " + name).Error; err != nil {
+		return errors.Wrap(err, "failed to create savepoint")
+	}
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			tx.Exec("ROLLBACK TO SAVEPOINT " + name)
+		}
+	}()
+
+	err = fn(ctx, tx)
+	if err == nil {
+		err = tx.Exec("RELEASE SAVEPOINT " + name).Error
+	}
+	panicked = false
+	return err
+}
+
+// IsRetryableTxError returns whether the given error is a serialization
+// failure or a deadlock (SerializationFailure category), after which the
+// transaction can be retried.
+func IsRetryableTxError(err error) bool {
+	return ErrorCategoryOf(err) == SerializationFailure
+}
@@ -0,0 +1,219 @@
+package orm_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
+	"github.com/cryptogarageinc/server-common-go/test"
+	"github.com/jackc/pgconn"
+	"github.com/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type TestTxItem struct {
+	ID   uint
+	Name string
+}
+
+func countTxItems(ormInstance *orm.ORM) int64 {
+	var count int64
+	ormInstance.GetDB().Model(&TestTxItem{}).Count(&count)
+	return count
+}
+
+func TestWithTransaction_NoError_Commits(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestTxItem{})
+	defer ormInstance.Finalize()
+	opts := &orm.TxOptions{Isolation: sql.LevelSerializable}
+
+	// Act
+	err := ormInstance.WithTransaction(context.Background(), opts, func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Create(&TestTxItem{Name: "item"}).Error
+	})
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(int64(1), countTxItems(ormInstance))
+}
+
+func TestWithTransaction_WithError_RollsBack(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestTxItem{})
+	defer ormInstance.Finalize()
+	expected := errors.New("error")
+
+	// Act
+	err := ormInstance.WithTransaction(context.Background(), nil, func(ctx context.Context, tx *gorm.DB) error {
+		if err := tx.Create(&TestTxItem{Name: "item"}).Error; err != nil {
+			return err
+		}
+		return expected
+	})
+
+	// Assert
+	assert.Equal(expected, err)
+	assert.Equal(int64(0), countTxItems(ormInstance))
+}
+
+func TestWithTransaction_WithPanic_RollsBack(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestTxItem{})
+	defer ormInstance.Finalize()
+
+	// Act
+	act := func() {
+		ormInstance.WithTransaction(context.Background(), nil, func(ctx context.Context, tx *gorm.DB) error {
+			tx.Create(&TestTxItem{Name: "item"})
+			panic("panic")
+		})
+	}
+
+	// Assert
+	assert.Panics(act)
+	assert.Equal(int64(0), countTxItems(ormInstance))
+}
+
+func TestWithTransaction_ContextDB_UsesTransaction(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestTxItem{})
+	defer ormInstance.Finalize()
+	var count int64
+
+	// Act
+	err := ormInstance.WithTransaction(context.Background(), nil, func(ctx context.Context, tx *gorm.DB) error {
+		if err := ormInstance.GetContextDB(ctx).Create(&TestTxItem{Name: "item"}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&TestTxItem{}).Count(&count).Error; err != nil {
+			return err
+		}
+		return errors.New("error")
+	})
+
+	// Assert
+	assert.Error(err)
+	assert.Equal(int64(1), count)
+	assert.Equal(int64(0), countTxItems(ormInstance))
+}
+
+func TestWithTransaction_Nested_RollsBackSavepoint(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestTxItem{})
+	defer ormInstance.Finalize()
+	var nestedErr error
+
+	// Act
+	err := ormInstance.WithTransaction(context.Background(), nil, func(ctx context.Context, tx *gorm.DB) error {
+		if err := tx.Create(&TestTxItem{Name: "outer"}).Error; err != nil {
+			return err
+		}
+		nestedErr = ormInstance.WithTransaction(ctx, nil, func(ctx context.Context, tx *gorm.DB) error {
+			if err := tx.Create(&TestTxItem{Name: "inner"}).Error; err != nil {
+				return err
+			}
+			return errors.New("error")
+		})
+		return ormInstance.WithTransaction(ctx, nil, func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Create(&TestTxItem{Name: "inner2"}).Error
+		})
+	})
+
+	// Assert
+	assert.NoError(err)
+	assert.Error(nestedErr)
+	var items []TestTxItem
+	ormInstance.GetDB().Order("id").Find(&items)
+	if assert.Len(items, 2) {
+		assert.Equal("outer", items[0].Name)
+		assert.Equal("inner2", items[1].Name)
+	}
+}
+
+func TestWithTransaction_SerializationFailure_Retries(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestTxItem{})
+	defer ormInstance.Finalize()
+	attempts := 0
+
+	// Act
+	err := ormInstance.WithTransaction(context.Background(), nil, func(ctx context.Context, tx *gorm.DB) error {
+		attempts++
+		if err := tx.Create(&TestTxItem{Name: "item"}).Error; err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return errors.Wrap(&pgconn.PgError{Code: "40001"}, "failed")
+		}
+		return nil
+	})
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(3, attempts)
+	assert.Equal(int64(1), countTxItems(ormInstance))
+}
+
+func TestWithTransaction_DeadlockExceedingRetries_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestTxItem{})
+	defer ormInstance.Finalize()
+	attempts := 0
+	opts := &orm.TxOptions{MaxRetries: 1}
+
+	// Act
+	err := ormInstance.WithTransaction(context.Background(), opts, func(ctx context.Context, tx *gorm.DB) error {
+		attempts++
+		return &pgconn.PgError{Code: "40P01"}
+	})
+
+	// Assert
+	assert.True(orm.IsRetryableTxError(err))
+	assert.Equal(2, attempts)
+}
+
+func TestWithTransaction_OtherError_DoesNotRetry(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestTxItem{})
+	defer ormInstance.Finalize()
+	attempts := 0
+
+	// Act
+	err := ormInstance.WithTransaction(context.Background(), nil, func(ctx context.Context, tx *gorm.DB) error {
+		attempts++
+		return &pgconn.PgError{Code: "23505"}
+	})
+
+	// Assert
+	assert.Error(err)
+	assert.False(orm.IsRetryableTxError(err))
+	assert.Equal(1, attempts)
+}
+
+func TestGetContextDB_WithoutTransaction_ReturnsDB(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ormInstance := test.NewOrm(&TestTxItem{})
+	defer ormInstance.Finalize()
+
+	// Act
+	db := ormInstance.GetContextDB(context.Background())
+	_, ok := orm.TxFromContext(context.Background())
+
+	// Assert
+	assert.NoError(db.Create(&TestTxItem{Name: "item"}).Error)
+	assert.False(ok)
+	assert.Equal(int64(1), countTxItems(ormInstance))
+}
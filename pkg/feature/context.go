@@ -0,0 +1,45 @@
+package feature
+
+import "context"
+
+type contextKey int
+
+const (
+	evaluatorKey contextKey = iota
+	attributesKey
+)
+
+// NewContext returns a copy of ctx carrying the given evaluator.
+func NewContext(ctx context.Context, evaluator *Evaluator) context.Context {
+	return context.WithValue(ctx, evaluatorKey, evaluator)
+}
+
+// FromContext returns the evaluator carried by ctx, or nil.
+func FromContext(ctx context.Context) *Evaluator {
+	evaluator, _ := ctx.Value(evaluatorKey).(*Evaluator)
+	return evaluator
+}
+
+// WithAttributes returns a copy of ctx carrying the given attributes, merged
+// with the attributes already carried by ctx. The attributes of the context
+// are used by all the evaluations using it.
+func WithAttributes(ctx context.Context, attributes Attributes) context.Context {
+	return context.WithValue(ctx, attributesKey, mergeAttributes(AttributesFromContext(ctx), attributes))
+}
+
+// AttributesFromContext returns the attributes carried by ctx, or nil.
+func AttributesFromContext(ctx context.Context) Attributes {
+	attributes, _ := ctx.Value(attributesKey).(Attributes)
+	return attributes
+}
+
+// IsEnabled returns whether the feature flag with the given name is enabled
+// using the evaluator and the attributes carried by ctx. It returns false if
+// ctx does not carry an evaluator.
+func IsEnabled(ctx context.Context, name string, attributes Attributes) bool {
+	evaluator := FromContext(ctx)
+	if evaluator == nil {
+		return false
+	}
+	return evaluator.IsEnabled(ctx, name, attributes)
+}
@@ -0,0 +1,135 @@
+package feature
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+	"sync"
+
+	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
+)
+
+// rolloutBuckets is the number of buckets of the percentage rollouts, giving a
+// precision of 0.01%.
+const rolloutBuckets = 10000
+
+// Attributes describes the subject of a flag evaluation, e.g. its user_id or
+// tenant_id.
+type Attributes map[string]string
+
+// Evaluator evaluates the feature flags of a configuration.
+type Evaluator struct {
+	mu     sync.RWMutex
+	config *Config
+}
+
+// NewEvaluator returns a new evaluator of the given feature flags.
+func NewEvaluator(config *Config) *Evaluator {
+	return &Evaluator{config: config}
+}
+
+// Subscribe registers the evaluator to the changes of the given configuration
+// so that the feature flags are updated without restart.
+// The feature configuration of the evaluator must have been initialized from c.
+func (e *Evaluator) Subscribe(c *conf.Configuration) error {
+	return c.Subscribe(e.getConfig(), e.onConfigChange)
+}
+
+// onConfigChange applies the new feature flags.
+func (e *Evaluator) onConfigChange(oldConf, newConf interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.config = newConf.(*Config)
+}
+
+func (e *Evaluator) getConfig() *Config {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config
+}
+
+// IsEnabled returns whether the feature flag with the given name is enabled
+// for the given attributes, completed by the attributes of the context (see
+// WithAttributes). Unknown flags are disabled.
+// The flag and attribute names are case insensitive, the configuration keys
+// being lowercased when they are read.
+// The percentage rollouts are deterministic: a flag is always enabled for the
+// same values of its rollout attribute, and increasing the percentage only
+// adds values.
+func (e *Evaluator) IsEnabled(ctx context.Context, name string, attributes Attributes) bool {
+	name = strings.ToLower(name)
+	flag, ok := lookupFlag(e.getConfig().Flags, name)
+	if !ok || !flag.Enabled {
+		return false
+	}
+	attributes = lowerAttributes(mergeAttributes(AttributesFromContext(ctx), attributes))
+
+	for attribute, values := range flag.Allow {
+		value, ok := attributes[strings.ToLower(attribute)]
+		if !ok {
+			continue
+		}
+		for _, allowed := range values {
+			if value == allowed {
+				return true
+			}
+		}
+	}
+	if flag.Rollout == nil {
+		return len(flag.Allow) == 0
+	}
+	if *flag.Rollout >= 100 {
+		return true
+	}
+	value, ok := attributes[strings.ToLower(flag.RolloutAttribute)]
+	if !ok {
+		return false
+	}
+	return float64(bucket(name, value)) < *flag.Rollout*rolloutBuckets/100
+}
+
+// bucket returns the rollout bucket of the given value for the given flag.
+// The flag name is part of the hash so that the same values are not selected
+// by all the flags.
+func bucket(name, value string) uint64 {
+	sum := sha256.Sum256([]byte(name + ":" + value))
+	return binary.BigEndian.Uint64(sum[:8]) % rolloutBuckets
+}
+
+// lookupFlag returns the flag with the given lowercase name, the names of the
+// flags not read from a configuration possibly not being lowercase.
+func lookupFlag(flags map[string]Flag, name string) (Flag, bool) {
+	if flag, ok := flags[name]; ok {
+		return flag, true
+	}
+	for flagName, flag := range flags {
+		if strings.ToLower(flagName) == name {
+			return flag, true
+		}
+	}
+	return Flag{}, false
+}
+
+// lowerAttributes returns the attributes with lowercase names.
+func lowerAttributes(attributes Attributes) Attributes {
+	res := make(Attributes, len(attributes))
+	for k, v := range attributes {
+		res[strings.ToLower(k)] = v
+	}
+	return res
+}
+
+func mergeAttributes(base, overrides Attributes) Attributes {
+	if len(base) == 0 {
+		return overrides
+	}
+	res := make(Attributes, len(base)+len(overrides))
+	for k, v := range base {
+		res[k] = v
+	}
+	for k, v := range overrides {
+		res[k] = v
+	}
+	return res
+}
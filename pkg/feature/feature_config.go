@@ -0,0 +1,18 @@
+package feature
+
+// Config contains the feature flags, by name.
+type Config struct {
+	Flags map[string]Flag `configkey:"features"`
+}
+
+// Flag contains the conditions enabling a feature flag.
+// A disabled flag is disabled for everyone. An enabled flag is enabled for the
+// attributes matching its allow-list and, if a rollout is set, for the given
+// percentage of the values of the rollout attribute. An enabled flag without
+// allow-list nor rollout is enabled for everyone.
+type Flag struct {
+	Enabled          bool                `configkey:"enabled" desc:"Whether the feature can be enabled"`
+	Rollout          *float64            `configkey:"rollout" validate:"omitempty,min=0,max=100" desc:"Percentage of the values of the rollout attribute for which the feature is enabled"`
+	RolloutAttribute string              `configkey:"rollout_attribute" default:"user_id" desc:"Attribute whose value selects the rollout bucket"`
+	Allow            map[string][]string `configkey:"allow" desc:"Attribute values for which the feature is enabled, by attribute name (ex. user_id, tenant_id)"`
+}
@@ -0,0 +1,163 @@
+package feature
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
+	"github.com/stretchr/testify/assert"
+)
+
+const featureTestContent = `
+features:
+  new_ui:
+    enabled: true
+    rollout: 30
+  beta:
+    enabled: true
+    allow:
+      tenant_id: [t1, t2]
+  killed:
+    enabled: false
+  everyone:
+    enabled: true
+`
+
+func createEvaluator(t *testing.T, content string) *Evaluator {
+	config, err := conf.NewConfigurationFromReader("yaml", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("reading configuration failed: %s", err)
+	}
+	featureConfig := &Config{}
+	if err := config.InitializeComponentConfig(featureConfig); err != nil {
+		t.Fatalf("initializing feature configuration failed: %s", err)
+	}
+	return NewEvaluator(featureConfig)
+}
+
+func TestEvaluatorIsEnabled_WithFlags_ReturnsFlagState(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	evaluator := createEvaluator(t, featureTestContent)
+	ctx := context.Background()
+
+	// Act & Assert
+	assert.True(evaluator.IsEnabled(ctx, "everyone", nil))
+	assert.False(evaluator.IsEnabled(ctx, "killed", nil))
+	assert.False(evaluator.IsEnabled(ctx, "unknown", nil))
+	assert.True(evaluator.IsEnabled(ctx, "beta", Attributes{"tenant_id": "t2"}))
+	assert.False(evaluator.IsEnabled(ctx, "beta", Attributes{"tenant_id": "t3"}))
+	assert.False(evaluator.IsEnabled(ctx, "beta", nil))
+	assert.False(evaluator.IsEnabled(ctx, "new_ui", nil))
+}
+
+func TestEvaluatorIsEnabled_WithMixedCaseNames_MatchesFlag(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	evaluator := createEvaluator(t, `
+features:
+  newCheckout:
+    enabled: true
+    allow:
+      userId: [u1]
+`)
+	ctx := context.Background()
+
+	// Act & Assert
+	assert.True(evaluator.IsEnabled(ctx, "newCheckout", Attributes{"userId": "u1"}))
+	assert.True(evaluator.IsEnabled(ctx, "newcheckout", Attributes{"userid": "u1"}))
+	assert.False(evaluator.IsEnabled(ctx, "newCheckout", Attributes{"userId": "u2"}))
+}
+
+func TestEvaluatorIsEnabled_WithMixedCaseConfig_MatchesFlag(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	evaluator := NewEvaluator(&Config{Flags: map[string]Flag{
+		"newCheckout": {Enabled: true, Allow: map[string][]string{"userId": {"u1"}}},
+	}})
+
+	// Act
+	enabled := evaluator.IsEnabled(context.Background(), "newCheckout", Attributes{"userId": "u1"})
+
+	// Assert
+	assert.True(enabled)
+}
+
+func TestEvaluatorIsEnabled_WithRollout_EnablesPercentageDeterministically(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	evaluator := createEvaluator(t, featureTestContent)
+	ctx := context.Background()
+
+	// Act
+	enabled := 0
+	for i := 0; i < 10000; i++ {
+		attributes := Attributes{"user_id": fmt.Sprintf("user-%d", i)}
+		first := evaluator.IsEnabled(ctx, "new_ui", attributes)
+		second := evaluator.IsEnabled(ctx, "new_ui", attributes)
+		assert.Equal(first, second)
+		if first {
+			enabled++
+		}
+	}
+
+	// Assert
+	assert.InDelta(3000, enabled, 200)
+}
+
+func TestEvaluatorIsEnabled_WithContextAttributes_UsesContext(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	evaluator := createEvaluator(t, featureTestContent)
+	ctx := NewContext(context.Background(), evaluator)
+	ctx = WithAttributes(ctx, Attributes{"tenant_id": "t1"})
+
+	// Act
+	actual := IsEnabled(ctx, "beta", nil)
+	overridden := IsEnabled(ctx, "beta", Attributes{"tenant_id": "t3"})
+
+	// Assert
+	assert.True(actual)
+	assert.False(overridden)
+	assert.False(IsEnabled(context.Background(), "everyone", nil))
+}
+
+func TestEvaluatorSubscribe_WithReload_UpdatesFlags(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "feature.yaml")
+	ioutil.WriteFile(path, []byte("features:\n  flag:\n    enabled: false\n"), 0600)
+	config := conf.NewConfiguration("feature", "feature", []string{dir})
+	assert.NoError(config.Initialize())
+	featureConfig := &Config{}
+	assert.NoError(config.InitializeComponentConfig(featureConfig))
+	evaluator := NewEvaluator(featureConfig)
+	assert.NoError(evaluator.Subscribe(config))
+	ioutil.WriteFile(path, []byte("features:\n  flag:\n    enabled: true\n"), 0600)
+
+	// Act
+	err := config.Reload()
+
+	// Assert
+	assert.NoError(err)
+	assert.True(evaluator.IsEnabled(context.Background(), "flag", nil))
+}
+
+func TestConfig_WithInvalidRollout_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config, _ := conf.NewConfigurationFromReader("yaml", strings.NewReader("features:\n  flag:\n    rollout: 120\n"))
+	featureConfig := &Config{}
+
+	// Act
+	err := config.InitializeComponentConfig(featureConfig)
+
+	// Assert
+	assert.Error(err)
+	assert.Contains(err.Error(), "features.flag.rollout")
+}
@@ -0,0 +1,62 @@
+package feature
+
+import (
+	"context"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/feature"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// AttributesFunc extracts the feature flag attributes of a call from its
+// context.
+type AttributesFunc func(ctx context.Context) feature.Attributes
+
+// UnaryServerInterceptor returns a unary server interceptor which adds the
+// feature flag evaluator, and the attributes extracted from the call if
+// attributes is not nil, to the context of the calls. Handlers evaluate the
+// flags with feature.IsEnabled(ctx, name, nil).
+func UnaryServerInterceptor(evaluator *feature.Evaluator, attributes AttributesFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(newContext(ctx, evaluator, attributes), req)
+	}
+}
+
+// StreamServerInterceptor returns a stream server interceptor which adds the
+// feature flag evaluator, and the attributes extracted from the call if
+// attributes is not nil, to the context of the streams.
+func StreamServerInterceptor(evaluator *feature.Evaluator, attributes AttributesFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		wrapped := grpc_middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = newContext(stream.Context(), evaluator, attributes)
+		return handler(srv, wrapped)
+	}
+}
+
+// AttributesFromMetadata returns an AttributesFunc reading the attributes from
+// the incoming metadata, mapping metadata keys to attribute names (e.g.
+// {"x-user-id": "user_id"}).
+func AttributesFromMetadata(keys map[string]string) AttributesFunc {
+	return func(ctx context.Context) feature.Attributes {
+		res := feature.Attributes{}
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return res
+		}
+		for key, attribute := range keys {
+			if values := md.Get(key); len(values) > 0 && values[0] != "" {
+				res[attribute] = values[0]
+			}
+		}
+		return res
+	}
+}
+
+func newContext(ctx context.Context, evaluator *feature.Evaluator, attributes AttributesFunc) context.Context {
+	ctx = feature.NewContext(ctx, evaluator)
+	if attributes != nil {
+		ctx = feature.WithAttributes(ctx, attributes(ctx))
+	}
+	return ctx
+}
@@ -0,0 +1,33 @@
+package feature
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/feature"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUnaryServerInterceptor_WithMetadata_AddsEvaluatorAndAttributes(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	evaluator := feature.NewEvaluator(&feature.Config{Flags: map[string]feature.Flag{
+		"beta": {Enabled: true, Allow: map[string][]string{"user_id": {"u1"}}},
+	}})
+	interceptor := UnaryServerInterceptor(evaluator, AttributesFromMetadata(map[string]string{"x-user-id": "user_id"}))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", "u1"))
+	var enabled bool
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		enabled = feature.IsEnabled(ctx, "beta", nil)
+		return nil, nil
+	}
+
+	// Act
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+
+	// Assert
+	assert.NoError(err)
+	assert.True(enabled)
+}
@@ -0,0 +1,30 @@
+package pagination
+
+import (
+	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Request is a request message with the page_token and page_size fields,
+// implemented by the generated messages:
+//	message ListUsersRequest {
+//		string page_token = 1;
+//		int32 page_size = 2;
+//	}
+type Request interface {
+	GetPageToken() string
+	GetPageSize() int32
+}
+
+// ParsePageRequest returns the page request of the page_token and page_size
+// fields of the request message. The returned error, due to an invalid field,
+// is a status error with the codes.InvalidArgument code which can be returned
+// by the handler.
+func ParsePageRequest(req Request, codec *orm.PageTokenCodec) (*orm.PageRequest, error) {
+	page, err := codec.ParsePageRequest(req.GetPageToken(), int(req.GetPageSize()))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return page, nil
+}
@@ -0,0 +1,60 @@
+package pagination_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
+	"github.com/cryptogarageinc/server-common-go/pkg/grpc/pagination"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type listRequest struct {
+	pageToken string
+	pageSize  int32
+}
+
+func (r *listRequest) GetPageToken() string { return r.pageToken }
+func (r *listRequest) GetPageSize() int32   { return r.pageSize }
+
+var codec = orm.NewPageTokenCodec([]byte("0123456789abcdef0123456789abcdef"))
+
+func TestParsePageRequest_ValidRequest_ReturnsPageRequest(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	token := &orm.PageToken{Order: []string{"id"}, Keys: []json.RawMessage{json.RawMessage("5")}}
+	value, _ := codec.Encode(token)
+
+	// Act
+	page, err := pagination.ParsePageRequest(&listRequest{pageToken: value, pageSize: 20}, codec)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(20, page.Size)
+	assert.Equal(token, page.Token)
+}
+
+func TestParsePageRequest_InvalidToken_ReturnsInvalidArgument(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	_, err := pagination.ParsePageRequest(&listRequest{pageToken: "forged"}, codec)
+
+	// Assert
+	assert.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func TestParsePageRequest_NegativeSize_ReturnsInvalidArgument(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	_, err := pagination.ParsePageRequest(&listRequest{pageSize: -1}, codec)
+
+	// Assert
+	assert.Equal(codes.InvalidArgument, status.Code(err))
+}
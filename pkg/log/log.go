@@ -4,7 +4,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 
+	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
 	rotatelogs "github.com/lestrrat/go-file-rotatelogs"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -12,6 +14,8 @@ import (
 
 // Log is used by the application to log information.
 type Log struct {
+	// configMu guards config, replaced when the configuration is reloaded.
+	configMu    sync.RWMutex
 	config      *Config
 	rotateLog   *rotatelogs.RotateLogs
 	Logger      *logrus.Logger
@@ -30,7 +34,7 @@ func NewLog(config *Config) *Log {
 func (l *Log) Initialize() error {
 	var w io.Writer
 
-	stdoutFlag := l.config.OutputStdout
+	stdoutFlag := l.getConfig().OutputStdout
 	if stdoutFlag {
 		w = os.Stdout
 	} else {
@@ -54,40 +58,100 @@ func (l *Log) Initialize() error {
 
 // initializeRotateLog initialize the rotating log for the log instance.
 func (l *Log) initializeRotateLog() (*rotatelogs.RotateLogs, error) {
-	count := l.config.RotationCount
-	interval := l.config.RotationInterval
+	config := l.getConfig()
+	count := config.RotationCount
+	interval := config.RotationInterval
 
 	logOption := []rotatelogs.Option{
 		rotatelogs.WithRotationCount(count),
 		rotatelogs.WithRotationTime(interval),
 	}
-	dir := l.config.LogDir
-	basename := l.config.LogFileBaseName
+	dir := config.LogDir
+	basename := config.LogFileBaseName
 	return rotatelogs.New(filepath.Join(dir, basename), logOption...)
 }
 
 // initializeLogrus initializes the Logrus for the log instance.
 func (l *Log) initializeLogrus(writer io.Writer) (*logrus.Logger, error) {
+	config := l.getConfig()
 	logger := logrus.New()
 	logger.SetOutput(writer)
-	var formatter logrus.Formatter
-	f := l.config.LogFormat
+	formatter, err := newFormatter(config.LogFormat)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetFormatter(formatter)
+	level, err := parseLevel(config.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetLevel(level)
+	return logger, nil
+}
+
+// newFormatter returns the logrus formatter matching the given format.
+func newFormatter(f string) (logrus.Formatter, error) {
 	switch f {
 	case "json":
-		formatter = &logrus.JSONFormatter{}
+		return &logrus.JSONFormatter{}, nil
 	case "text":
-		formatter = &logrus.TextFormatter{FullTimestamp: true, QuoteEmptyFields: true}
+		return &logrus.TextFormatter{FullTimestamp: true, QuoteEmptyFields: true}, nil
 	default:
 		return nil, errors.Errorf("illegal log format [%s], specify \"text\" or \"json\" with \"log.format\" key", f)
 	}
-	logger.SetFormatter(formatter)
-	v := l.config.LogLevel
+}
+
+// parseLevel returns the logrus level matching the given level name.
+func parseLevel(v string) (logrus.Level, error) {
 	level, err := logrus.ParseLevel(v)
 	if err != nil {
-		return nil, errors.Wrapf(err, "illegal log level [%s]", v)
+		return level, errors.Wrapf(err, "illegal log level [%s]", v)
 	}
-	logger.SetLevel(level)
-	return logger, nil
+	return level, nil
+}
+
+// Subscribe registers the log instance to the changes of the given
+// configuration so that the log level and format are updated without restart.
+// The log configuration of the instance must have been initialized from c.
+func (l *Log) Subscribe(c *conf.Configuration) error {
+	return c.Subscribe(l.getConfig(), l.onConfigChange)
+}
+
+// getConfig returns the current log configuration.
+func (l *Log) getConfig() *Config {
+	l.configMu.RLock()
+	defer l.configMu.RUnlock()
+	return l.config
+}
+
+func (l *Log) setConfig(config *Config) {
+	l.configMu.Lock()
+	defer l.configMu.Unlock()
+	l.config = config
+}
+
+// onConfigChange applies the level and format of the new log configuration.
+func (l *Log) onConfigChange(oldConf, newConf interface{}) {
+	config := newConf.(*Config)
+	if !l.initialized {
+		l.setConfig(config)
+		return
+	}
+
+	formatter, err := newFormatter(config.LogFormat)
+	if err != nil {
+		l.Logger.WithError(err).Error("failed to apply new log format")
+		return
+	}
+	level, err := parseLevel(config.LogLevel)
+	if err != nil {
+		l.Logger.WithError(err).Error("failed to apply new log level")
+		return
+	}
+	l.Logger.SetFormatter(formatter)
+	l.Logger.SetLevel(level)
+	l.setConfig(config)
+	l.Logger.Infof("log configuration reloaded (level: %s, format: %s)", config.LogLevel, config.LogFormat)
 }
 
 // IsInitialized returns whether the log instance is initialized.
@@ -105,6 +169,12 @@ func (l *Log) Finalize() error {
 	return nil
 }
 
+// LogConfiguration logs the effective configuration at info level, the values
+// of secret fields being redacted.
+func (l *Log) LogConfiguration(c *conf.Configuration) {
+	l.Logger.WithField("configuration", c.AllSettings()).Info("effective configuration")
+}
+
 // NewEntry creates a new entry.
 func (l *Log) NewEntry() *logrus.Entry {
 	return logrus.NewEntry(l.Logger)
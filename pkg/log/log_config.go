@@ -4,11 +4,11 @@ import "time"
 
 // Config contains the configuration parameters for the log.
 type Config struct {
-	OutputStdout     bool          `configkey:"log.output_stdout"`
-	RotationCount    int           `configkey:"log.rotation_counts" validate:"required_without=OutputStdout"`
-	RotationInterval time.Duration `configkey:"log.rotation_interval,duration" validate:"required_without=OutputStdout"`
-	LogDir           string        `configkey:"log.dir" validate:"required_without=OutputStdout"`
-	LogFileBaseName  string        `configkey:"log.basename" validate:"required_without=OutputStdout"`
-	LogFormat        string        `configkey:"log.format" validate:"eq=json|eq=text"`
-	LogLevel         string        `configkey:"log.level" validate:"required"`
+	OutputStdout     bool          `configkey:"log.output_stdout" desc:"Whether to write the logs to the standard output instead of files" example:"true"`
+	RotationCount    int           `configkey:"log.rotation_counts" validate:"required_without=OutputStdout" desc:"Number of log files kept" example:"7"`
+	RotationInterval time.Duration `configkey:"log.rotation_interval,duration" validate:"required_without=OutputStdout" desc:"Interval between two log file rotations" example:"24h"`
+	LogDir           string        `configkey:"log.dir" validate:"required_without=OutputStdout" desc:"Directory of the log files" example:"_log"`
+	LogFileBaseName  string        `configkey:"log.basename" validate:"required_without=OutputStdout" desc:"Name pattern of the log files (ex. app.log.%Y-%m-%d)" example:"app.log.%Y-%m-%d"`
+	LogFormat        string        `configkey:"log.format" validate:"eq=json|eq=text" desc:"Format of the logs, text or json" example:"json"`
+	LogLevel         string        `configkey:"log.level" validate:"required" desc:"Minimum level of the logs (ex. info)" example:"info"`
 }
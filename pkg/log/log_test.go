@@ -2,6 +2,7 @@ package log
 
 import (
 	"io/ioutil"
+	"path/filepath"
 	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
 	"strings"
 	"testing"
@@ -176,3 +177,61 @@ func TestLog_InitializeFinalizeWithRotateLog_NoError(t *testing.T) {
 	assert.NoError(err1)
 	assert.NoError(err2)
 }
+
+func TestLog_SubscribeWithLevelChanged_UpdatesLevel(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logtest.yaml")
+	ioutil.WriteFile(path, []byte("log:\n  format: text\n  output_stdout: true\n  level: info\n"), 0600)
+	config := conf.NewConfiguration("logtest", "logtest", []string{dir})
+	config.Initialize()
+	logConfig := &Config{}
+	config.InitializeComponentConfig(logConfig)
+	l := NewLog(logConfig)
+	l.Initialize()
+	l.Logger.SetOutput(ioutil.Discard)
+	defer l.Finalize()
+	err1 := l.Subscribe(config)
+	ioutil.WriteFile(path, []byte("log:\n  format: json\n  output_stdout: true\n  level: debug\n"), 0600)
+
+	// Act
+	err2 := config.Reload()
+
+	// Assert
+	assert.NoError(err1)
+	assert.NoError(err2)
+	assert.Equal(logrus.DebugLevel, l.Logger.GetLevel())
+	_, ok := l.Logger.Formatter.(*logrus.JSONFormatter)
+	assert.True(ok)
+}
+
+func TestLog_LogConfiguration_RedactsSecrets(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	configProperties := `log.format=json
+	log.output_stdout=true
+	log.level=info
+	database.dbpassword=1234
+	`
+	config, _ := conf.NewConfigurationFromReader(
+		"properties", strings.NewReader(configProperties))
+	config.InitializeComponentConfig(&struct {
+		Password string `configkey:"database.dbpassword,secret"`
+	}{})
+	logConfig := Config{}
+	config.InitializeComponentConfig(&logConfig)
+	l := NewLog(&logConfig)
+	l.Initialize()
+	defer l.Finalize()
+	var out strings.Builder
+	l.Logger.SetOutput(&out)
+
+	// Act
+	l.LogConfiguration(config)
+
+	// Assert
+	assert.Contains(out.String(), `"dbpassword":"******"`)
+	assert.Contains(out.String(), `"level":"info"`)
+	assert.NotContains(out.String(), "1234")
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+
+	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigurationDump returns a handler responding with the effective
+// configuration, the values of secret fields being redacted.
+// The format is selected with the "format" query parameter: "json" (default)
+// or "yaml".
+// The handler is meant for admin routes and should not be publicly exposed.
+func ConfigurationDump(config *conf.Configuration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch format := c.DefaultQuery("format", "json"); format {
+		case "json":
+			c.JSON(http.StatusOK, config.AllSettings())
+		case "yaml", "yml":
+			dump, err := config.Dump(format)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to dump configuration"})
+				return
+			}
+			c.Data(http.StatusOK, "application/x-yaml; charset=utf-8", dump)
+		default:
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "unsupported format " + format})
+		}
+	}
+}
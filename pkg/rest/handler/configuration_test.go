@@ -0,0 +1,71 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	conf "github.com/cryptogarageinc/server-common-go/pkg/configuration"
+	"github.com/cryptogarageinc/server-common-go/pkg/rest/handler"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newConfigurationRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	config, _ := conf.NewConfigurationFromReader(
+		"yaml", strings.NewReader("admin:\n  password: s3cr3t\n  port: 8081\n"))
+	config.InitializeComponentConfig(&struct {
+		Password string `configkey:"admin.password,secret"`
+	}{})
+	router := gin.New()
+	router.GET("/config", handler.ConfigurationDump(config))
+	return router
+}
+
+func TestConfigurationDump_WithJSONFormat_RespondsRedactedConfiguration(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	router := newConfigurationRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/config", nil)
+
+	// Act
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(http.StatusOK, w.Code)
+	assert.JSONEq(`{"admin":{"password":"******","port":8081}}`, w.Body.String())
+}
+
+func TestConfigurationDump_WithYamlFormat_RespondsYaml(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	router := newConfigurationRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/config?format=yaml", nil)
+
+	// Act
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), "port: 8081")
+	assert.NotContains(w.Body.String(), "s3cr3t")
+}
+
+func TestConfigurationDump_WithUnknownFormat_RespondsBadRequest(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	router := newConfigurationRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/config?format=xml", nil)
+
+	// Act
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// Query parameters of the page requests.
+const (
+	PageTokenParam = "page_token"
+	PageSizeParam  = "page_size"
+)
+
+// ParsePageRequest returns the page request of the page_token and page_size
+// query parameters of the request. The returned error, due to an invalid
+// parameter, should be answered with http.StatusBadRequest.
+// ex.)
+//	page, err := handler.ParsePageRequest(c, codec)
+//	if err != nil {
+//		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+//		return
+//	}
+func ParsePageRequest(c *gin.Context, codec *orm.PageTokenCodec) (*orm.PageRequest, error) {
+	size := 0
+	if value := c.Query(PageSizeParam); value != "" {
+		var err error
+		if size, err = strconv.Atoi(value); err != nil {
+			return nil, errors.Errorf("invalid page size [%s]", value)
+		}
+	}
+	return codec.ParsePageRequest(c.Query(PageTokenParam), size)
+}
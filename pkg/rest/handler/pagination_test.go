@@ -0,0 +1,88 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/database/orm"
+	"github.com/cryptogarageinc/server-common-go/pkg/rest/handler"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var pageTokenCodec = orm.NewPageTokenCodec([]byte("0123456789abcdef0123456789abcdef"))
+
+func newPaginationRouter(page **orm.PageRequest) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/items", func(c *gin.Context) {
+		parsed, err := handler.ParsePageRequest(c, pageTokenCodec)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		*page = parsed
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestParsePageRequest_WithParams_ReturnsPageRequest(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	var page *orm.PageRequest
+	router := newPaginationRouter(&page)
+	token := &orm.PageToken{Order: []string{"id"}, Keys: []json.RawMessage{json.RawMessage("5")}}
+	value, _ := pageTokenCodec.Encode(token)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/items?page_size=20&page_token="+url.QueryEscape(value), nil)
+
+	// Act
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(20, page.Size)
+	assert.Equal(token, page.Token)
+}
+
+func TestParsePageRequest_WithoutParams_ReturnsFirstPage(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	var page *orm.PageRequest
+	router := newPaginationRouter(&page)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/items", nil)
+
+	// Act
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(orm.DefaultPageSize, page.Size)
+	assert.Nil(page.Token)
+}
+
+func TestParsePageRequest_InvalidParams_RespondsBadRequest(t *testing.T) {
+	for _, query := range []string{"page_size=abc", "page_size=-1", "page_token=forged"} {
+		t.Run(query, func(t *testing.T) {
+			// Arrange
+			assert := assert.New(t)
+			var page *orm.PageRequest
+			router := newPaginationRouter(&page)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/items?"+query, nil)
+
+			// Act
+			router.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(http.StatusBadRequest, w.Code)
+			assert.Nil(page)
+		})
+	}
+}
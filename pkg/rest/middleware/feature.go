@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/cryptogarageinc/server-common-go/pkg/feature"
+	"github.com/gin-gonic/gin"
+)
+
+// FeatureAttributesFunc extracts the feature flag attributes of a request.
+type FeatureAttributesFunc func(c *gin.Context) feature.Attributes
+
+// Features returns a gin middleware function which adds the feature flag
+// evaluator, and the attributes extracted from the request if attributes is
+// not nil, to the request context. Handlers evaluate the flags with
+// feature.IsEnabled(c.Request.Context(), name, nil).
+func Features(evaluator *feature.Evaluator, attributes FeatureAttributesFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := feature.NewContext(c.Request.Context(), evaluator)
+		if attributes != nil {
+			ctx = feature.WithAttributes(ctx, attributes(c))
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// FeatureAttributesFromHeaders returns a FeatureAttributesFunc reading the
+// attributes from the request headers, mapping header names to attribute
+// names (e.g. {"X-User-Id": "user_id"}).
+func FeatureAttributesFromHeaders(headers map[string]string) FeatureAttributesFunc {
+	return func(c *gin.Context) feature.Attributes {
+		res := feature.Attributes{}
+		for header, attribute := range headers {
+			if value := c.GetHeader(header); value != "" {
+				res[attribute] = value
+			}
+		}
+		return res
+	}
+}
@@ -0,0 +1,71 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cryptogarageinc/server-common-go/pkg/feature"
+	"github.com/cryptogarageinc/server-common-go/pkg/rest/middleware"
+	"github.com/gin-gonic/gin"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newFeatureTestEngine(attributes middleware.FeatureAttributesFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	evaluator := feature.NewEvaluator(&feature.Config{Flags: map[string]feature.Flag{
+		"beta":     {Enabled: true, Allow: map[string][]string{"user_id": {"u1"}}},
+		"everyone": {Enabled: true},
+	}})
+	engine := gin.New()
+	engine.Use(middleware.Features(evaluator, attributes))
+	engine.GET("/", func(c *gin.Context) {
+		if feature.IsEnabled(c.Request.Context(), c.Query("flag"), nil) {
+			c.String(http.StatusOK, "enabled")
+			return
+		}
+		c.String(http.StatusOK, "disabled")
+	})
+	return engine
+}
+
+func serveFeatureTestRequest(engine *gin.Engine, flag, userID string) string {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/?flag="+flag, nil)
+	if userID != "" {
+		req.Header.Set("X-User-Id", userID)
+	}
+	engine.ServeHTTP(w, req)
+	return w.Body.String()
+}
+
+func TestFeatures_WithHeaderAttributes_EvaluatesFlags(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	engine := newFeatureTestEngine(middleware.FeatureAttributesFromHeaders(map[string]string{"X-User-Id": "user_id"}))
+
+	// Act
+	allowed := serveFeatureTestRequest(engine, "beta", "u1")
+	other := serveFeatureTestRequest(engine, "beta", "u2")
+	missing := serveFeatureTestRequest(engine, "beta", "")
+
+	// Assert
+	assert.Equal("enabled", allowed)
+	assert.Equal("disabled", other)
+	assert.Equal("disabled", missing)
+}
+
+func TestFeatures_WithoutAttributes_AddsEvaluator(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	engine := newFeatureTestEngine(nil)
+
+	// Act
+	everyone := serveFeatureTestRequest(engine, "everyone", "u1")
+	beta := serveFeatureTestRequest(engine, "beta", "u1")
+
+	// Assert
+	assert.Equal("enabled", everyone)
+	assert.Equal("disabled", beta)
+}
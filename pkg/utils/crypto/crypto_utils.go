@@ -1,8 +1,11 @@
 package crypto
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 
 	"golang.org/x/crypto/argon2"
 )
@@ -47,3 +50,57 @@ func IsPasswordValid(
 		argon2.Key([]byte(password), []byte(salt), time, memory, threads, keyLen))
 	return hashedPassword == protectedForm[saltStringLen:]
 }
+
+// GenerateKey returns a securely generated random key of the given size, e.g.
+// 32 bytes for AES-256.
+func GenerateKey(keySize int) ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncryptAESGCM encrypts and authenticates the plaintext with AES-GCM, the key
+// size selecting AES-128, AES-192 or AES-256. The additional data, which may
+// be nil, is authenticated but not encrypted and must be given again to
+// decrypt the value. The returned value is as follow:
+// nonce + ciphertext + tag
+func EncryptAESGCM(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// DecryptAESGCM decrypts a value returned by EncryptAESGCM. An error is
+// returned if the value was not encrypted with the given key and additional
+// data or was modified.
+func DecryptAESGCM(key, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce := ciphertext[:gcm.NonceSize()]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], additionalData)
+	if err != nil {
+		return nil, errors.New("message authentication failed")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
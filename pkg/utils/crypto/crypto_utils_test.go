@@ -65,3 +65,68 @@ func TestIsPasswordValid_WithInvalidPassword_ReturnsFalse(t *testing.T) {
 	// Assert
 	assert.False(isValid)
 }
+
+func TestGenerateKey_With32_Returns32Bytes(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	// Act
+	key, err := GenerateKey(keyLen)
+	// Assert
+	assert.NoError(err)
+	assert.Len(key, keyLen)
+}
+
+func TestDecryptAESGCM_WithEncryptedValue_ReturnsPlaintext(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key, _ := GenerateKey(keyLen)
+	ciphertext, _ := EncryptAESGCM(key, []byte("secret"), []byte("data"))
+
+	// Act
+	plaintext, err := DecryptAESGCM(key, ciphertext, []byte("data"))
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]byte("secret"), plaintext)
+}
+
+func TestDecryptAESGCM_WithOtherKey_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key, _ := GenerateKey(keyLen)
+	otherKey, _ := GenerateKey(keyLen)
+	ciphertext, _ := EncryptAESGCM(key, []byte("secret"), []byte("data"))
+
+	// Act
+	_, err := DecryptAESGCM(otherKey, ciphertext, []byte("data"))
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestDecryptAESGCM_WithModifiedValue_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key, _ := GenerateKey(keyLen)
+	ciphertext, _ := EncryptAESGCM(key, []byte("secret"), []byte("data"))
+	ciphertext[len(ciphertext)-1] ^= 1
+
+	// Act
+	_, err := DecryptAESGCM(key, ciphertext, []byte("data"))
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestDecryptAESGCM_WithOtherAdditionalData_ReturnsError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key, _ := GenerateKey(keyLen)
+	ciphertext, _ := EncryptAESGCM(key, []byte("secret"), []byte("data"))
+
+	// Act
+	_, err := DecryptAESGCM(key, ciphertext, []byte("other"))
+
+	// Assert
+	assert.Error(err)
+}